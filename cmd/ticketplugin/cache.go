@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	coreticket "github.com/opsorch/opsorch-core/ticket"
+	adapter "github.com/opsorch/opsorch-jira-adapter/ticket"
+)
+
+// defaultMaxProviders bounds how many distinct configs keep a live provider.
+const defaultMaxProviders = 16
+
+// providerCache keeps one provider per distinct config. Configs are keyed by
+// a hash of their contents, so a rotated token or changed project builds a
+// fresh provider instead of reusing stale credentials. Each Jira target
+// (site and project) holds at most one entry: a new config for the same
+// target replaces the old instance, and the least recently used entry is
+// evicted once the cache is full.
+type providerCache struct {
+	build func(map[string]any) (coreticket.Provider, error)
+	max   int
+
+	mu       sync.Mutex
+	entries  map[string]*cachedProvider
+	byTarget map[string]string
+}
+
+// cachedProvider is a provider together with the OAuth tokens it refreshed
+// that have not yet been reported to Core.
+type cachedProvider struct {
+	provider coreticket.Provider
+	hash     string
+	target   string
+	lastUsed time.Time
+
+	mu        sync.Mutex
+	refreshed *adapter.OAuthToken
+}
+
+func newProviderCache(build func(map[string]any) (coreticket.Provider, error), max int) *providerCache {
+	return &providerCache{
+		build:    build,
+		max:      max,
+		entries:  map[string]*cachedProvider{},
+		byTarget: map[string]string{},
+	}
+}
+
+// get returns the provider for cfg, building it on first use. Building
+// makes no network calls, so it happens under the lock; concurrent requests
+// for a new config therefore share a single instance. With outboxDir set,
+// the first build for a directory also reads the queued writes from disk
+// while the lock is held.
+func (c *providerCache) get(cfg map[string]any) (*cachedProvider, error) {
+	hash, err := configHash(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[hash]; ok {
+		e.lastUsed = time.Now()
+		return e, nil
+	}
+
+	prov, err := c.build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	e := &cachedProvider{provider: prov, hash: hash, target: configTarget(cfg), lastUsed: time.Now()}
+	if jp, ok := prov.(*adapter.JiraProvider); ok {
+		jp.OnTokenRefresh(e.setRefreshed)
+	}
+
+	// The previous config for this target is superseded (for example by a
+	// rotated token); drop it so its credentials are never used again.
+	if old, ok := c.byTarget[e.target]; ok {
+		c.entries[old].close()
+		delete(c.entries, old)
+	}
+	c.entries[hash] = e
+	c.byTarget[e.target] = hash
+
+	for c.max > 0 && len(c.entries) > c.max {
+		c.evictOldest()
+	}
+	return e, nil
+}
+
+func (c *providerCache) evictOldest() {
+	var oldest *cachedProvider
+	for _, e := range c.entries {
+		if oldest == nil || e.lastUsed.Before(oldest.lastUsed) {
+			oldest = e
+		}
+	}
+	oldest.close()
+	delete(c.entries, oldest.hash)
+	if c.byTarget[oldest.target] == oldest.hash {
+		delete(c.byTarget, oldest.target)
+	}
+}
+
+// close stops the provider's background work, such as outbox replay.
+// Requests already holding it may still finish.
+func (e *cachedProvider) close() {
+	if c, ok := e.provider.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+func (c *providerCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (e *cachedProvider) setRefreshed(tok adapter.OAuthToken) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.refreshed = &tok
+}
+
+// takeRefreshed returns the latest unreported token pair, if any, and clears
+// it so it is reported only once.
+func (e *cachedProvider) takeRefreshed() *adapter.OAuthToken {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	tok := e.refreshed
+	e.refreshed = nil
+	return tok
+}
+
+// configHash identifies a config by its contents. encoding/json sorts map
+// keys, so equal configs always hash the same.
+func configHash(cfg map[string]any) (string, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("hash config: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// configTarget names the Jira site and project a config points at, which
+// stays the same when only credentials or tuning change.
+func configTarget(cfg map[string]any) string {
+	return fmt.Sprintf("%v|%v|%v|%v", cfg["apiURL"], cfg["cloudId"], cfg["projectKey"], cfg["source"])
+}
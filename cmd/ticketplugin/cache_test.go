@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	coreticket "github.com/opsorch/opsorch-core/ticket"
+	adapter "github.com/opsorch/opsorch-jira-adapter/ticket"
+)
+
+func testConfig(project, token string) map[string]any {
+	return map[string]any{
+		"apiURL":     "https://example.atlassian.net",
+		"email":      "ops@example.com",
+		"apiToken":   token,
+		"projectKey": project,
+	}
+}
+
+func TestProviderCache(t *testing.T) {
+	builds := 0
+	cache := newProviderCache(func(cfg map[string]any) (coreticket.Provider, error) {
+		builds++
+		return adapter.New(cfg)
+	}, 2)
+
+	first, err := cache.get(testConfig("OPS", "token-1"))
+	if err != nil {
+		t.Fatalf("get() error = %v", err)
+	}
+
+	t.Run("same config reuses the provider", func(t *testing.T) {
+		// A fresh map with equal contents must hash the same.
+		again, err := cache.get(testConfig("OPS", "token-1"))
+		if err != nil {
+			t.Fatalf("get() error = %v", err)
+		}
+		if again != first || builds != 1 {
+			t.Errorf("provider rebuilt for an unchanged config (builds = %d)", builds)
+		}
+	})
+
+	t.Run("rotated token replaces the stale instance", func(t *testing.T) {
+		rotated, err := cache.get(testConfig("OPS", "token-2"))
+		if err != nil {
+			t.Fatalf("get() error = %v", err)
+		}
+		if rotated == first {
+			t.Fatal("rotated config returned the stale provider")
+		}
+		if n := cache.len(); n != 1 {
+			t.Errorf("len = %d, want 1 after replacing the stale config", n)
+		}
+	})
+
+	t.Run("distinct targets coexist up to the limit", func(t *testing.T) {
+		cache.get(testConfig("SEC", "token-3"))
+		if n := cache.len(); n != 2 {
+			t.Errorf("len = %d, want 2", n)
+		}
+		cache.get(testConfig("ENG", "token-4"))
+		if n := cache.len(); n != 2 {
+			t.Errorf("len = %d, want 2 after evicting the least recently used", n)
+		}
+		if _, ok := cache.entries[mustHash(t, testConfig("OPS", "token-2"))]; ok {
+			t.Error("least recently used provider was not evicted")
+		}
+	})
+
+	t.Run("invalid config is not cached", func(t *testing.T) {
+		_, err := cache.get(map[string]any{"apiURL": "https://example.atlassian.net"})
+		if err == nil {
+			t.Fatal("expected error for config without credentials")
+		}
+		if n := cache.len(); n != 2 {
+			t.Errorf("len = %d, want 2", n)
+		}
+	})
+}
+
+func TestProviderCacheConcurrent(t *testing.T) {
+	cache := newProviderCache(adapter.New, 4)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cfg := testConfig(fmt.Sprintf("P%d", i%8), "token")
+			e, err := cache.get(cfg)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if e.takeRefreshed() != nil {
+				errs <- errors.New("unexpected refreshed token")
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := cache.len(); n > 4 {
+		t.Errorf("len = %d, want at most 4", n)
+	}
+}
+
+// closingProvider counts Close calls.
+type closingProvider struct {
+	stubProvider
+	closed *int
+}
+
+func (p closingProvider) Close() error {
+	*p.closed++
+	return nil
+}
+
+func TestProviderCacheClosesDroppedProviders(t *testing.T) {
+	closed := map[string]*int{}
+	cache := newProviderCache(func(cfg map[string]any) (coreticket.Provider, error) {
+		n := new(int)
+		closed[fmt.Sprint(cfg["projectKey"], cfg["apiToken"])] = n
+		return closingProvider{closed: n}, nil
+	}, 2)
+
+	cache.get(testConfig("OPS", "token-1"))
+	cache.get(testConfig("OPS", "token-2"))
+	if *closed["OPStoken-1"] != 1 {
+		t.Error("replaced provider was not closed")
+	}
+	cache.get(testConfig("SEC", "token-3"))
+	cache.get(testConfig("ENG", "token-4"))
+	if *closed["OPStoken-2"] != 1 {
+		t.Error("evicted provider was not closed")
+	}
+	if *closed["SECtoken-3"] != 0 || *closed["ENGtoken-4"] != 0 {
+		t.Error("a cached provider was closed")
+	}
+}
+
+func mustHash(t *testing.T, cfg map[string]any) string {
+	t.Helper()
+	h, err := configHash(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
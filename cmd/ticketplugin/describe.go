@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	adapter "github.com/opsorch/opsorch-jira-adapter/ticket"
+)
+
+// startedAt is reported as uptime by plugin.health.
+var startedAt = time.Now()
+
+// ticketMethods lists the provider methods served by dispatch.
+var ticketMethods = []string{
+	"ticket.query",
+	"ticket.query.page",
+	"ticket.get",
+	"ticket.create",
+	"ticket.update",
+	"ticket.comment.list",
+	"ticket.comment.add",
+	"ticket.comment.update",
+	"ticket.comment.delete",
+	"ticket.attachment.list",
+	"ticket.attachment.upload",
+	"ticket.attachment.download",
+	"ticket.outbox.status",
+	"ticket.outbox.replay",
+}
+
+// pluginMethods are answered by the plugin itself and need no config.
+var pluginMethods = []string{
+	handshakeMethod,
+	"plugin.describe",
+	"plugin.capabilities",
+	"plugin.health",
+	cancelMethod,
+}
+
+// features names optional behaviour beyond the core ticket contract, so
+// hosts can enable UI and workflows only when the plugin supports them.
+var features = []string{
+	"pagination",
+	"comments",
+	"attachments",
+	"transitions",
+	"markdownDescriptions",
+	"mentions",
+	"dataCenter",
+	"oauth2",
+	"concurrency",
+	"cancellation",
+	"jsonrpc2",
+	"batch",
+	"http",
+	"rateLimiting",
+	"circuitBreaker",
+	"outbox",
+	"idempotentCreate",
+	"deduplication",
+}
+
+type capabilities struct {
+	Methods  []string `json:"methods"`
+	Features []string `json:"features"`
+}
+
+type description struct {
+	Name         string   `json:"name"`
+	Capability   string   `json:"capability"`
+	Version      string   `json:"version"`
+	RequiresCore string   `json:"requiresCore"`
+	Protocols    []string `json:"protocols"`
+	capabilities
+	ConfigSchema json.RawMessage `json:"configSchema"`
+}
+
+type health struct {
+	Status        string `json:"status"`
+	Version       string `json:"version"`
+	UptimeSeconds int64  `json:"uptimeSeconds"`
+	InFlight      int    `json:"inFlight"`
+	Providers     int    `json:"providers"`
+	// Config is reported when the request carries a config, telling the host
+	// whether a provider can be built from it.
+	Config *configHealth `json:"config,omitempty"`
+}
+
+type configHealth struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+	// Breaker is the circuit breaker state of the provider built from the
+	// config.
+	Breaker *adapter.BreakerStatus `json:"breaker,omitempty"`
+	// Outbox is reported when the config enables the durable outbox.
+	Outbox *adapter.OutboxStatus `json:"outbox,omitempty"`
+}
+
+func currentCapabilities() capabilities {
+	methods := append(append([]string(nil), ticketMethods...), pluginMethods...)
+	return capabilities{Methods: methods, Features: features}
+}
+
+// servePlugin answers the plugin.* discovery methods; ok is false for any
+// other method.
+func (s *server) servePlugin(ctx context.Context, req rpcRequest) (result any, ok bool, err error) {
+	switch req.Method {
+	case "plugin.describe":
+		return description{
+			Name:         adapter.ProviderName,
+			Capability:   "ticket",
+			Version:      adapter.AdapterVersion,
+			RequiresCore: adapter.RequiresCore,
+			Protocols:    []string{protocolLegacy, protocolJSONRPC},
+			capabilities: currentCapabilities(),
+			ConfigSchema: adapter.ConfigSchema(),
+		}, true, nil
+	case "plugin.capabilities":
+		return currentCapabilities(), true, nil
+	case "plugin.health":
+		h := health{
+			Status:        "ok",
+			Version:       adapter.AdapterVersion,
+			UptimeSeconds: int64(time.Since(startedAt).Seconds()),
+			InFlight:      s.inFlight(),
+			Providers:     providers.len(),
+		}
+		if len(req.Config) > 0 {
+			h.Config = &configHealth{Valid: true}
+			e, err := providers.get(req.Config)
+			if err != nil {
+				h.Config = &configHealth{Error: err.Error()}
+			} else if jp, ok := e.provider.(*adapter.JiraProvider); ok {
+				b := jp.Breaker()
+				h.Config.Breaker = &b
+				if b.State != adapter.BreakerClosed {
+					h.Status = "degraded"
+				}
+				if ob, err := jp.Outbox(); ob.Enabled && err == nil {
+					h.Config.Outbox = &ob
+					if ob.Depth > 0 {
+						h.Status = "degraded"
+					}
+				}
+			}
+		}
+		return h, true, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	adapter "github.com/opsorch/opsorch-jira-adapter/ticket"
+)
+
+func TestTicketMethodsAreDispatched(t *testing.T) {
+	prov, err := adapter.New(testConfig("OPS", "token"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	// A cancelled context makes every call fail before reaching Jira; only a
+	// methodError means dispatch does not know the method.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, method := range ticketMethods {
+		_, err := dispatch(ctx, prov, rpcRequest{Method: method, Payload: json.RawMessage(`{}`)})
+		var me *methodError
+		if errors.As(err, &me) {
+			t.Errorf("%s is advertised but not dispatched: %v", method, err)
+		}
+	}
+}
+
+func TestUnknownMethodNeedsNoConfig(t *testing.T) {
+	// The real provider cache rejects an empty config; an unknown method
+	// must be reported as such instead.
+	_, _, err := serve(context.Background(), rpcRequest{Method: "ticket.nope"})
+	var me *methodError
+	if !errors.As(err, &me) {
+		t.Fatalf("serve() error = %v, want a methodError", err)
+	}
+	if code := toJSONRPCError(err).Code; code != codeMethodNotFound {
+		t.Errorf("code = %d, want %d", code, codeMethodNotFound)
+	}
+}
+
+func TestPluginDiscovery(t *testing.T) {
+	useStubProvider(t)
+	c := startServer(t, 2)
+	defer c.stop()
+
+	t.Run("describe", func(t *testing.T) {
+		c.send(`{"method":"plugin.describe"}`)
+		resp := c.recv()
+		if resp.Error != "" {
+			t.Fatalf("error = %s", resp.Error)
+		}
+		var d struct {
+			Name         string          `json:"name"`
+			Version      string          `json:"version"`
+			RequiresCore string          `json:"requiresCore"`
+			Methods      []string        `json:"methods"`
+			Features     []string        `json:"features"`
+			ConfigSchema json.RawMessage `json:"configSchema"`
+		}
+		if err := json.Unmarshal(resp.Result, &d); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if d.Name != "jira" || d.Version != adapter.AdapterVersion || d.RequiresCore != adapter.RequiresCore {
+			t.Errorf("description = %+v", d)
+		}
+		if len(d.Methods) != len(ticketMethods)+len(pluginMethods) || len(d.Features) == 0 {
+			t.Errorf("methods = %v, features = %v", d.Methods, d.Features)
+		}
+		if !json.Valid(d.ConfigSchema) || len(d.ConfigSchema) < 2 {
+			t.Errorf("configSchema = %s", d.ConfigSchema)
+		}
+	})
+
+	t.Run("capabilities", func(t *testing.T) {
+		c.send(`{"id":1,"method":"plugin.capabilities"}`)
+		resp := c.recv()
+		var caps capabilities
+		if err := json.Unmarshal(resp.Result, &caps); err != nil || len(caps.Methods) == 0 {
+			t.Errorf("capabilities = %s (%v)", resp.Result, err)
+		}
+	})
+
+	t.Run("health", func(t *testing.T) {
+		c.send(`{"method":"plugin.health"}`)
+		var h health
+		if err := json.Unmarshal(c.recv().Result, &h); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if h.Status != "ok" || h.Version != adapter.AdapterVersion || h.Config != nil {
+			t.Errorf("health = %+v", h)
+		}
+
+		c.send(`{"method":"plugin.health","config":{"projectKey":"OPS"}}`)
+		if err := json.Unmarshal(c.recv().Result, &h); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if h.Config == nil || !h.Config.Valid {
+			t.Errorf("config health = %+v", h.Config)
+		}
+	})
+}
+
+func TestHealthReportsBreaker(t *testing.T) {
+	prev := providers
+	providers = newProviderCache(adapter.New, defaultMaxProviders)
+	t.Cleanup(func() { providers = prev })
+
+	s := &server{}
+	result, ok, err := s.servePlugin(context.Background(), rpcRequest{Method: "plugin.health", Config: testConfig("OPS", "token")})
+	if !ok || err != nil {
+		t.Fatalf("servePlugin() = %v, %v", ok, err)
+	}
+	h := result.(health)
+	if h.Status != "ok" || h.Config == nil || h.Config.Breaker == nil || h.Config.Breaker.State != adapter.BreakerClosed {
+		t.Errorf("health = %+v, config = %+v", h, h.Config)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// authTokenEnv names the environment variable holding the bearer token that
+// HTTP clients must present. It is read from the environment rather than a
+// flag so the token does not show up in process listings.
+const authTokenEnv = "TICKETPLUGIN_AUTH_TOKEN"
+
+// errUnauthorized rejects HTTP requests without the expected bearer token.
+var errUnauthorized = errors.New("missing or invalid bearer token")
+
+// maxHTTPBody bounds a request body; attachment uploads travel base64-encoded
+// inside it.
+const maxHTTPBody = 64 << 20
+
+// httpStatuses maps JSON-RPC error codes onto HTTP statuses. Jira rejecting
+// the adapter's credentials is an upstream failure, not the caller's, so it
+// is reported as a bad gateway.
+var httpStatuses = map[int]int{
+	codeParseError:       http.StatusBadRequest,
+	codeInvalidRequest:   http.StatusBadRequest,
+	codeMethodNotFound:   http.StatusNotFound,
+	codeInvalidParams:    http.StatusBadRequest,
+	codeNotFound:         http.StatusNotFound,
+	codeUnauthorized:     http.StatusBadGateway,
+	codeForbidden:        http.StatusBadGateway,
+	codeRateLimited:      http.StatusTooManyRequests,
+	codeConflict:         http.StatusConflict,
+	codeValidation:       http.StatusBadRequest,
+	codeCancelled:        http.StatusServiceUnavailable,
+	codeDeadlineExceeded: http.StatusGatewayTimeout,
+	codeShuttingDown:     http.StatusServiceUnavailable,
+	codeUnavailable:      http.StatusServiceUnavailable,
+}
+
+// listenAndServe serves the plugin methods over HTTP on addr, a TCP address
+// or "unix:" followed by a socket path, until ctx ends. Shutdown drains like
+// the stdio mode: new connections are refused and requests in flight get
+// drainTimeout to finish before they are cancelled.
+func (s *server) listenAndServe(ctx context.Context, addr, token string) error {
+	ln, err := listen(addr)
+	if err != nil {
+		return err
+	}
+
+	base, abort := context.WithCancelCause(context.WithoutCancel(ctx))
+	defer abort(nil)
+	hs := &http.Server{
+		Handler:           s.httpHandler(ctx, token),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return base },
+	}
+
+	errs := make(chan error, 1)
+	go func() { errs <- hs.Serve(ln) }()
+	s.log.Printf("listening on %s", addr)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.log.Printf("shutting down: draining in-flight requests")
+	drainCtx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancel()
+	if err := hs.Shutdown(drainCtx); err != nil {
+		s.log.Printf("drain timeout: cancelling remaining requests")
+		abort(errShutdown)
+		hs.Close()
+	}
+	s.wg.Wait()
+	return nil
+}
+
+// listen opens a TCP listener, or a unix socket for "unix:" addresses. A
+// socket left behind by a previous run is removed first.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// httpHandler routes the HTTP endpoints. /healthz and /readyz are open so
+// orchestrators can probe them; /rpc requires the bearer token when one is
+// set. /readyz fails once ctx ends so load balancers stop routing here.
+func (s *server) httpHandler(ctx context.Context, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if ctx.Err() != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	})
+	mux.Handle("POST /rpc", requireBearer(token, http.HandlerFunc(s.serveHTTP)))
+	return mux
+}
+
+// serveHTTP answers one request in the legacy message format. The response
+// body has the same shape as on stdio, and the status classifies failures.
+func (s *server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var req rpcRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHTTPBody)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, rpcResponse{Error: err.Error()})
+		return
+	}
+
+	var o outcome
+	switch req.Method {
+	case handshakeMethod:
+		o.err = &methodError{msg: "plugin.handshake is not supported over HTTP"}
+	case cancelMethod:
+		cancelled, err := s.cancel(req.Payload)
+		o = outcome{result: map[string]bool{"cancelled": cancelled}, err: err}
+	default:
+		o = s.call(r.Context(), req)
+	}
+
+	resp := rpcResponse{ID: req.ID, Credentials: o.creds}
+	status := http.StatusOK
+	if o.err != nil {
+		resp.Error = o.err.Error()
+		rpcErr := toJSONRPCError(o.err)
+		status = http.StatusInternalServerError
+		if st, ok := httpStatuses[rpcErr.Code]; ok {
+			status = st
+		}
+		if rpcErr.Data != nil && rpcErr.Data.RetryAfterMs > 0 {
+			w.Header().Set("Retry-After", strconv.FormatInt((rpcErr.Data.RetryAfterMs+999)/1000, 10))
+		}
+	} else {
+		resp.Result = o.result
+	}
+	writeJSON(w, status, resp)
+}
+
+// call serves req on a worker slot and waits for its outcome. Unlike stdio,
+// id-less HTTP requests are concurrent too, so they also take a slot.
+func (s *server) call(ctx context.Context, req rpcRequest) outcome {
+	if requestKey(req.ID) != "" {
+		done := make(chan outcome, 1)
+		s.submit(ctx, req, func(o outcome) { done <- o })
+		return <-done
+	}
+	select {
+	case s.workers <- struct{}{}:
+		defer func() { <-s.workers }()
+		return s.handle(ctx, req, "")
+	case <-ctx.Done():
+		return outcome{err: ctx.Err()}
+	}
+}
+
+// requireBearer rejects requests without the expected bearer token. An empty
+// token disables authentication.
+func requireBearer(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ticketplugin"`)
+			writeJSON(w, http.StatusUnauthorized, rpcResponse{Error: errUnauthorized.Error()})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
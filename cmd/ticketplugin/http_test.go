@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHTTPServer(t *testing.T, ctx context.Context, token string) *httptest.Server {
+	t.Helper()
+	srv := newServer(io.Discard, 2)
+	srv.log = log.New(io.Discard, "", 0)
+	ts := httptest.NewServer(srv.httpHandler(ctx, token))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func postRPC(t *testing.T, client *http.Client, url, token, body string) (int, testResponse) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url+"/rpc", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("POST /rpc: %v", err)
+	}
+	defer resp.Body.Close()
+	var out testResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return resp.StatusCode, out
+}
+
+func TestHTTPMode(t *testing.T) {
+	useStubProvider(t)
+	ts := newTestHTTPServer(t, context.Background(), "s3cret")
+
+	t.Run("rpc", func(t *testing.T) {
+		status, resp := postRPC(t, ts.Client(), ts.URL, "s3cret", `{"id":1,"method":"ticket.get","config":{},"payload":{"id":"PROJ-1"}}`)
+		if status != http.StatusOK || resp.Error != "" || string(resp.ID) != "1" || !strings.Contains(string(resp.Result), `"PROJ-1"`) {
+			t.Errorf("status %d, response %+v", status, resp)
+		}
+	})
+
+	t.Run("errors are classified", func(t *testing.T) {
+		tests := []struct {
+			body string
+			want int
+		}{
+			{`{"method":"ticket.get","config":{},"payload":{"id":"missing"}}`, http.StatusNotFound},
+			{`{"method":"ticket.nope","config":{}}`, http.StatusNotFound},
+			{`{"method":"ticket.get","config":{},"payload":"nope"}`, http.StatusBadRequest},
+			{`{"method":"ticket.get","config":{},"payload":{"id":"panic"}}`, http.StatusInternalServerError},
+			{`{"method":"ticket.get","config":{},"payload":{"id":"slow"},"timeoutMs":10}`, http.StatusGatewayTimeout},
+			{`not json`, http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			status, resp := postRPC(t, ts.Client(), ts.URL, "s3cret", tt.body)
+			if status != tt.want || resp.Error == "" {
+				t.Errorf("%s: status %d, response %+v, want %d", tt.body, status, resp, tt.want)
+			}
+		}
+	})
+
+	t.Run("bearer auth", func(t *testing.T) {
+		for _, token := range []string{"", "wrong"} {
+			status, resp := postRPC(t, ts.Client(), ts.URL, token, `{"method":"plugin.health"}`)
+			if status != http.StatusUnauthorized || resp.Error != errUnauthorized.Error() {
+				t.Errorf("token %q: status %d, response %+v", token, status, resp)
+			}
+		}
+	})
+
+	t.Run("probes need no token", func(t *testing.T) {
+		for _, path := range []string{"/healthz", "/readyz"} {
+			resp, err := ts.Client().Get(ts.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("%s = %d", path, resp.StatusCode)
+			}
+		}
+	})
+}
+
+func TestHTTPReadyzDuringShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := newTestHTTPServer(t, ctx, "")
+	cancel()
+
+	resp, err := ts.Client().Get(ts.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("/readyz = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestListenAndServeUnixSocket(t *testing.T) {
+	useStubProvider(t)
+	sock := filepath.Join(t.TempDir(), "plugin.sock")
+	srv := newServer(io.Discard, 2)
+	srv.log = log.New(io.Discard, "", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.listenAndServe(ctx, "unix:"+sock, "") }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		},
+	}}
+	var status int
+	var resp testResponse
+	for i := 0; ; i++ {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			conn.Close()
+			status, resp = postRPC(t, client, "http://plugin", "", `{"method":"ticket.get","config":{},"payload":{"id":"PROJ-9"}}`)
+			break
+		}
+		if i == 100 {
+			t.Fatal("socket never came up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status != http.StatusOK || resp.Error != "" {
+		t.Errorf("status %d, response %+v", status, resp)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("listenAndServe() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+}
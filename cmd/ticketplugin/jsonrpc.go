@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+	adapter "github.com/opsorch/opsorch-jira-adapter/ticket"
+)
+
+// Protocols a client can select with plugin.handshake.
+const (
+	protocolLegacy  = "legacy"
+	protocolJSONRPC = "jsonrpc-2.0"
+)
+
+// handshakeMethod negotiates the wire protocol. It is accepted in either
+// framing and answered in the framing it arrived in; every later message is
+// read in the negotiated protocol.
+const handshakeMethod = "plugin.handshake"
+
+// credentialsMethod is the JSON-RPC notification that reports refreshed
+// OAuth tokens, since JSON-RPC responses cannot carry extra members.
+const credentialsMethod = "$/credentials"
+
+// JSON-RPC error codes. The -326xx/-327xx codes are defined by the spec; the
+// -320xx codes classify adapter failures and are stable across releases.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+
+	codeNotFound         = -32001
+	codeUnauthorized     = -32002
+	codeForbidden        = -32003
+	codeRateLimited      = -32004
+	codeConflict         = -32005
+	codeValidation       = -32006
+	codeCancelled        = -32007
+	codeDeadlineExceeded = -32008
+	codeShuttingDown     = -32009
+	codeUnavailable      = -32010
+)
+
+// orchErrCodes maps opsorch-core error codes onto JSON-RPC codes.
+var orchErrCodes = map[string]int{
+	"not_found":    codeNotFound,
+	"unauthorized": codeUnauthorized,
+	"forbidden":    codeForbidden,
+	"rate_limited": codeRateLimited,
+	"conflict":     codeConflict,
+	"bad_request":  codeValidation,
+	"unavailable":  codeUnavailable,
+}
+
+type jsonrpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// jsonrpcParams carries what the legacy protocol sends as top-level members.
+type jsonrpcParams struct {
+	Config    map[string]any  `json:"config"`
+	Payload   json.RawMessage `json:"payload"`
+	Deadline  *time.Time      `json:"deadline,omitempty"`
+	TimeoutMs int64           `json:"timeoutMs,omitempty"`
+}
+
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+}
+
+type jsonrpcNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type jsonrpcError struct {
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Data    *jsonrpcData `json:"data,omitempty"`
+}
+
+// jsonrpcData gives clients the details behind a classified failure.
+type jsonrpcData struct {
+	// Kind is the opsorch-core error code, e.g. "not_found".
+	Kind         string            `json:"kind,omitempty"`
+	Status       int               `json:"status,omitempty"`
+	FieldErrors  map[string]string `json:"fieldErrors,omitempty"`
+	RetryAfterMs int64             `json:"retryAfterMs,omitempty"`
+}
+
+// paramsError marks a payload the plugin could not decode.
+type paramsError struct{ err error }
+
+func (e *paramsError) Error() string { return e.err.Error() }
+func (e *paramsError) Unwrap() error { return e.err }
+
+// methodError marks a method the plugin or provider does not implement.
+type methodError struct{ msg string }
+
+func (e *methodError) Error() string { return e.msg }
+
+// decodePayload unmarshals a request payload, classifying failures as
+// invalid params.
+func decodePayload(raw json.RawMessage, v any) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &paramsError{err: err}
+	}
+	return nil
+}
+
+// toJSONRPCError classifies err onto a stable error code.
+func toJSONRPCError(err error) *jsonrpcError {
+	rpcErr := &jsonrpcError{Code: codeInternalError, Message: err.Error()}
+
+	var pe *paramsError
+	var me *methodError
+	var oe orcherr.OpsOrchError
+	switch {
+	case errors.As(err, &me):
+		rpcErr.Code = codeMethodNotFound
+	case errors.As(err, &pe):
+		rpcErr.Code = codeInvalidParams
+	case errors.Is(err, errDuplicateID):
+		rpcErr.Code = codeInvalidRequest
+	case errors.Is(err, errCancelled):
+		rpcErr.Code = codeCancelled
+	case errors.Is(err, errShutdown):
+		rpcErr.Code = codeShuttingDown
+	case errors.Is(err, context.DeadlineExceeded):
+		rpcErr.Code = codeDeadlineExceeded
+	case errors.As(err, &oe):
+		if code, ok := orchErrCodes[oe.Code]; ok {
+			rpcErr.Code = code
+		}
+		rpcErr.Data = &jsonrpcData{Kind: oe.Code}
+	}
+
+	var apiErr *adapter.APIError
+	if errors.As(err, &apiErr) {
+		if rpcErr.Data == nil {
+			rpcErr.Data = &jsonrpcData{}
+		}
+		rpcErr.Data.Status = apiErr.StatusCode
+		rpcErr.Data.FieldErrors = apiErr.FieldErrors
+		rpcErr.Data.RetryAfterMs = apiErr.RetryAfter.Milliseconds()
+	}
+
+	var fieldsErr *adapter.TransitionFieldsError
+	if errors.As(err, &fieldsErr) {
+		if rpcErr.Data == nil {
+			rpcErr.Data = &jsonrpcData{}
+		}
+		rpcErr.Data.FieldErrors = map[string]string{}
+		for id := range fieldsErr.Missing {
+			rpcErr.Data.FieldErrors[id] = fmt.Sprintf("required by transition %q", fieldsErr.Transition)
+		}
+	}
+	return rpcErr
+}
+
+// negotiateProtocol picks the first protocol in the client's preference list
+// that the plugin speaks, falling back to the legacy protocol.
+func negotiateProtocol(payload json.RawMessage) (string, error) {
+	var req struct {
+		Protocols []string `json:"protocols"`
+	}
+	if len(payload) > 0 {
+		if err := decodePayload(payload, &req); err != nil {
+			return "", err
+		}
+	}
+	for _, p := range req.Protocols {
+		if p == protocolJSONRPC || p == protocolLegacy {
+			return p, nil
+		}
+	}
+	return protocolLegacy, nil
+}
+
+func handshakeResult(protocol string) map[string]string {
+	return map[string]string{"protocol": protocol, "version": adapter.AdapterVersion}
+}
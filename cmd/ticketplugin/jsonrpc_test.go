@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	adapter "github.com/opsorch/opsorch-jira-adapter/ticket"
+)
+
+type testRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *jsonrpcError   `json:"error"`
+}
+
+func (c *pluginConn) recvRPC() testRPCResponse {
+	line := c.recvRaw()
+	var resp testRPCResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		c.t.Fatalf("decode response %s: %v", line, err)
+	}
+	return resp
+}
+
+// startJSONRPC starts a server and negotiates JSON-RPC 2.0 with a legacy
+// framed handshake.
+func startJSONRPC(t *testing.T) *pluginConn {
+	c := startServer(t, 4)
+	c.send(`{"id":0,"method":"plugin.handshake","payload":{"protocols":["jsonrpc-2.0","legacy"]}}`)
+	resp := c.recv()
+	if resp.Error != "" || !strings.Contains(string(resp.Result), `"protocol":"jsonrpc-2.0"`) {
+		t.Fatalf("handshake response = %+v", resp)
+	}
+	return c
+}
+
+func TestJSONRPC(t *testing.T) {
+	useStubProvider(t)
+
+	t.Run("handshake defaults to legacy", func(t *testing.T) {
+		c := startServer(t, 1)
+		defer c.stop()
+
+		c.send(`{"method":"plugin.handshake","payload":{"protocols":["msgpack"]}}`)
+		if resp := c.recv(); !strings.Contains(string(resp.Result), `"protocol":"legacy"`) {
+			t.Errorf("handshake response = %+v", resp)
+		}
+		c.send(`{"method":"ticket.get","payload":{"id":"PROJ-1"}}`)
+		if resp := c.recv(); resp.Error != "" || !strings.Contains(string(resp.Result), "PROJ-1") {
+			t.Errorf("legacy response = %+v", resp)
+		}
+	})
+
+	t.Run("handshake in JSON-RPC framing", func(t *testing.T) {
+		c := startServer(t, 1)
+		defer c.stop()
+
+		c.send(`{"jsonrpc":"2.0","id":"hs","method":"plugin.handshake","params":{"protocols":["jsonrpc-2.0"]}}`)
+		resp := c.recvRPC()
+		if resp.JSONRPC != "2.0" || string(resp.ID) != `"hs"` || !strings.Contains(string(resp.Result), "jsonrpc-2.0") {
+			t.Errorf("handshake response = %+v", resp)
+		}
+	})
+
+	t.Run("requests and structured errors", func(t *testing.T) {
+		c := startJSONRPC(t)
+		defer c.stop()
+
+		c.send(`{"jsonrpc":"2.0","id":1,"method":"ticket.get","params":{"config":{},"payload":{"id":"PROJ-1"}}}`)
+		if resp := c.recvRPC(); resp.Error != nil || string(resp.ID) != "1" || !strings.Contains(string(resp.Result), "PROJ-1") {
+			t.Errorf("response = %+v", resp)
+		}
+
+		cases := []struct {
+			req  string
+			code int
+		}{
+			{`{"jsonrpc":"2.0","id":2,"method":"ticket.get","params":{"payload":{"id":"missing"}}}`, codeNotFound},
+			{`{"jsonrpc":"2.0","id":3,"method":"ticket.nope","params":{}}`, codeMethodNotFound},
+			{`{"jsonrpc":"2.0","id":4,"method":"ticket.get","params":{"payload":{"id":5}}}`, codeInvalidParams},
+			{`{"jsonrpc":"2.0","id":5,"method":"ticket.get","params":[1]}`, codeInvalidParams},
+			{`{"id":6,"method":"ticket.get"}`, codeInvalidRequest},
+			{`{"jsonrpc":"2.0","id":7,"method":"ticket.get","params":{"payload":{"id":"slow"},"timeoutMs":10}}`, codeDeadlineExceeded},
+		}
+		for _, tc := range cases {
+			c.send(tc.req)
+			resp := c.recvRPC()
+			if resp.Error == nil || resp.Error.Code != tc.code {
+				t.Errorf("%s: error = %+v, want code %d", tc.req, resp.Error, tc.code)
+			}
+		}
+	})
+
+	t.Run("not found carries its kind", func(t *testing.T) {
+		c := startJSONRPC(t)
+		defer c.stop()
+
+		c.send(`{"jsonrpc":"2.0","id":1,"method":"ticket.get","params":{"payload":{"id":"missing"}}}`)
+		resp := c.recvRPC()
+		if resp.Error == nil || resp.Error.Data == nil || resp.Error.Data.Kind != "not_found" {
+			t.Errorf("error = %+v, want data.kind not_found", resp.Error)
+		}
+	})
+
+	t.Run("notifications get no response", func(t *testing.T) {
+		c := startJSONRPC(t)
+		defer c.stop()
+
+		c.send(`{"jsonrpc":"2.0","method":"ticket.get","params":{"payload":{"id":"PROJ-1"}}}`)
+		c.send(`{"jsonrpc":"2.0","id":"after","method":"ticket.get","params":{"payload":{"id":"PROJ-2"}}}`)
+		if resp := c.recvRPC(); string(resp.ID) != `"after"` {
+			t.Errorf("response = %+v, want only the request's", resp)
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		c := startJSONRPC(t)
+		defer c.stop()
+
+		c.send(`[
+			{"jsonrpc":"2.0","id":1,"method":"ticket.get","params":{"payload":{"id":"PROJ-1"}}},
+			{"jsonrpc":"2.0","method":"ticket.get","params":{"payload":{"id":"PROJ-2"}}},
+			{"jsonrpc":"2.0","id":2,"method":"ticket.get","params":{"payload":{"id":"missing"}}},
+			{"foo":"bar"}
+		]`)
+		var batch []testRPCResponse
+		if err := json.Unmarshal(c.recvRaw(), &batch); err != nil {
+			t.Fatalf("decode batch: %v", err)
+		}
+		if len(batch) != 3 {
+			t.Fatalf("len(batch) = %d, want 3 (notification omitted)", len(batch))
+		}
+		if string(batch[0].ID) != "1" || batch[0].Error != nil {
+			t.Errorf("batch[0] = %+v", batch[0])
+		}
+		if batch[1].Error == nil || batch[1].Error.Code != codeNotFound {
+			t.Errorf("batch[1] = %+v", batch[1])
+		}
+		if string(batch[2].ID) != "null" || batch[2].Error == nil || batch[2].Error.Code != codeInvalidRequest {
+			t.Errorf("batch[2] = %+v", batch[2])
+		}
+
+		c.send(`[]`)
+		if resp := c.recvRPC(); resp.Error == nil || resp.Error.Code != codeInvalidRequest {
+			t.Errorf("empty batch = %+v", resp)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		c := startJSONRPC(t)
+		defer c.stop()
+
+		c.send(`{"jsonrpc":"2.0","id":1,"method":"ticket.get","params":{"payload":{"id":"slow"}}}`)
+		time.Sleep(20 * time.Millisecond)
+		c.send(`{"jsonrpc":"2.0","method":"$/cancel","params":{"id":1}}`)
+		if resp := c.recvRPC(); resp.Error == nil || resp.Error.Code != codeCancelled {
+			t.Errorf("response = %+v, want cancelled", resp)
+		}
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		c := startJSONRPC(t)
+		defer c.stop()
+
+		c.send(`{not json`)
+		if resp := c.recvRPC(); resp.Error == nil || resp.Error.Code != codeParseError || string(resp.ID) != "null" {
+			t.Errorf("response = %+v", resp)
+		}
+	})
+}
+
+func TestToJSONRPCError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"unauthorized", &adapter.APIError{StatusCode: http.StatusUnauthorized}, codeUnauthorized},
+		{"forbidden", fmt.Errorf("wrapped: %w", &adapter.APIError{StatusCode: http.StatusForbidden}), codeForbidden},
+		{"rate limited", &adapter.APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Second}, codeRateLimited},
+		{"conflict", adapter.ErrConflict, codeConflict},
+		{"validation", &adapter.APIError{StatusCode: http.StatusBadRequest, FieldErrors: map[string]string{"summary": "required"}}, codeValidation},
+		{"cancelled", errCancelled, codeCancelled},
+		{"deadline", fmt.Errorf("execute request: %w", context.DeadlineExceeded), codeDeadlineExceeded},
+		{"unclassified", fmt.Errorf("boom"), codeInternalError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toJSONRPCError(tt.err)
+			if got.Code != tt.code {
+				t.Errorf("code = %d, want %d", got.Code, tt.code)
+			}
+		})
+	}
+
+	got := toJSONRPCError(&adapter.APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Second})
+	if got.Data == nil || got.Data.Kind != "rate_limited" || got.Data.Status != 429 || got.Data.RetryAfterMs != 2000 {
+		t.Errorf("data = %+v", got.Data)
+	}
+	got = toJSONRPCError(&adapter.APIError{StatusCode: http.StatusBadRequest, FieldErrors: map[string]string{"summary": "required"}})
+	if got.Data == nil || got.Data.FieldErrors["summary"] != "required" {
+		t.Errorf("data = %+v", got.Data)
+	}
+	got = toJSONRPCError(fmt.Errorf("transition issue: %w", &adapter.TransitionFieldsError{Transition: "Resolve", Missing: map[string]string{"resolution": "Resolution"}}))
+	if got.Code != codeValidation || got.Data == nil || got.Data.Kind != "bad_request" || got.Data.FieldErrors["resolution"] != `required by transition "Resolve"` {
+		t.Errorf("code = %d, data = %+v", got.Code, got.Data)
+	}
+}
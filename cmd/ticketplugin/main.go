@@ -3,17 +3,23 @@ package main
 // The ticket plugin adapts the in-process Jira provider to OpsOrch Core's
 // JSON-RPC plugin contract. Core spawns this binary locally, writes request
 // objects (method/config/payload) to stdin, and reads responses from stdout.
+// With -listen it instead serves the same requests over HTTP, so several
+// callers can share one process and its Jira clients.
 // Each request includes the decrypted adapter config so secrets never leave the
-// host. The plugin lazily constructs a provider instance using that config and
-// reuses it for subsequent calls to avoid re-initialization overhead.
+// host. The plugin builds one provider per distinct config and reuses it for
+// subsequent calls to avoid re-initialization overhead.
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
+	"flag"
 	"io"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/opsorch/opsorch-core/schema"
 	coreticket "github.com/opsorch/opsorch-core/ticket"
@@ -21,103 +27,207 @@ import (
 )
 
 type rpcRequest struct {
+	// ID is echoed on the response. Requests with an id may be served
+	// concurrently and answered out of order.
+	ID      json.RawMessage `json:"id,omitempty"`
 	Method  string          `json:"method"`
 	Config  map[string]any  `json:"config"`
 	Payload json.RawMessage `json:"payload"`
+	// Deadline or TimeoutMs bound how long the request may run.
+	Deadline  *time.Time `json:"deadline,omitempty"`
+	TimeoutMs int64      `json:"timeoutMs,omitempty"`
 }
 
 type rpcResponse struct {
-	Result any    `json:"result,omitempty"`
-	Error  string `json:"error,omitempty"`
+	ID     json.RawMessage `json:"id,omitempty"`
+	Result any             `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	// Credentials carries OAuth tokens refreshed while serving the request so
+	// the host can persist the rotated refresh token.
+	Credentials *adapter.OAuthToken `json:"credentials,omitempty"`
 }
 
-var provider coreticket.Provider
+// commenter is implemented by providers that manage issue comments.
+type commenter interface {
+	ListComments(ctx context.Context, issueID string) ([]adapter.Comment, error)
+	AddComment(ctx context.Context, issueID, body string) (adapter.Comment, error)
+	UpdateComment(ctx context.Context, issueID, commentID, body string) (adapter.Comment, error)
+	DeleteComment(ctx context.Context, issueID, commentID string) error
+}
+
+// attacher is implemented by providers that manage issue attachments.
+type attacher interface {
+	ListAttachments(ctx context.Context, issueID string) ([]adapter.Attachment, error)
+	UploadAttachment(ctx context.Context, issueID, filename, contentType string, r io.Reader) (adapter.Attachment, error)
+	DownloadAttachment(ctx context.Context, attachmentID string) (adapter.Attachment, io.ReadCloser, error)
+}
+
+// downloadedAttachment is the ticket.attachment.download result.
+type downloadedAttachment struct {
+	Attachment adapter.Attachment `json:"attachment"`
+	Content    []byte             `json:"content"`
+}
+
+var providers = newProviderCache(adapter.New, defaultMaxProviders)
 
 func main() {
-	dec := json.NewDecoder(os.Stdin)
-	enc := json.NewEncoder(os.Stdout)
+	workers := flag.Int("workers", defaultWorkers, "maximum number of requests served concurrently")
+	drainTimeout := flag.Duration("drain-timeout", defaultDrainTimeout, "how long to wait for in-flight requests on shutdown")
+	listenAddr := flag.String("listen", "", `serve HTTP on this TCP address or "unix:/path/to.sock" instead of stdio`)
+	flag.Parse()
 
-	for {
-		var req rpcRequest
-		if err := dec.Decode(&req); err != nil {
-			if errors.Is(err, io.EOF) {
-				return
-			}
-			writeErr(enc, err)
-			return
-		}
+	// SIGTERM or SIGINT stops reading requests and drains the ones in
+	// flight; the process then exits normally.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-		prov, err := ensureProvider(req.Config)
-		if err != nil {
-			writeErr(enc, err)
-			continue
+	srv := newServer(os.Stdout, *workers)
+	srv.drainTimeout = *drainTimeout
+	if *listenAddr != "" {
+		if err := srv.listenAndServe(ctx, *listenAddr, os.Getenv(authTokenEnv)); err != nil {
+			srv.log.Print(err)
+			os.Exit(1)
 		}
+		return
+	}
+	if err := srv.run(ctx, os.Stdin); err != nil {
+		os.Exit(1)
+	}
+}
 
-		ctx := context.Background()
+// serve runs one request against the provider for its config and returns
+// any OAuth tokens refreshed along the way. Unknown methods are rejected
+// before the config is looked at, so they never surface as config errors.
+func serve(ctx context.Context, req rpcRequest) (any, *adapter.OAuthToken, error) {
+	if !slices.Contains(ticketMethods, req.Method) {
+		return nil, nil, &methodError{msg: "unknown method: " + req.Method}
+	}
+	entry, err := providers.get(req.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := dispatch(ctx, entry.provider, req)
+	return res, entry.takeRefreshed(), err
+}
+
+func dispatch(ctx context.Context, prov coreticket.Provider, req rpcRequest) (any, error) {
+	switch req.Method {
+	case "ticket.query":
+		var query schema.TicketQuery
+		if err := decodePayload(req.Payload, &query); err != nil {
+			return nil, err
+		}
+		return prov.Query(ctx, query)
+	case "ticket.query.page":
+		pager, ok := prov.(interface {
+			QueryPage(context.Context, schema.TicketQuery) (adapter.QueryResult, error)
+		})
+		if !ok {
+			return nil, &methodError{msg: "unsupported method: " + req.Method}
+		}
+		var query schema.TicketQuery
+		if err := decodePayload(req.Payload, &query); err != nil {
+			return nil, err
+		}
+		return pager.QueryPage(ctx, query)
+	case "ticket.get":
+		var payload struct {
+			ID string `json:"id"`
+		}
+		if err := decodePayload(req.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return prov.Get(ctx, payload.ID)
+	case "ticket.create":
+		var in schema.CreateTicketInput
+		if err := decodePayload(req.Payload, &in); err != nil {
+			return nil, err
+		}
+		return prov.Create(ctx, in)
+	case "ticket.update":
+		var payload struct {
+			ID    string                   `json:"id"`
+			Input schema.UpdateTicketInput `json:"input"`
+		}
+		if err := decodePayload(req.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return prov.Update(ctx, payload.ID, payload.Input)
+	case "ticket.comment.list", "ticket.comment.add", "ticket.comment.update", "ticket.comment.delete":
+		cm, ok := prov.(commenter)
+		if !ok {
+			return nil, &methodError{msg: "unsupported method: " + req.Method}
+		}
+		var payload struct {
+			ID        string `json:"id"`
+			CommentID string `json:"commentId"`
+			Body      string `json:"body"`
+		}
+		if err := decodePayload(req.Payload, &payload); err != nil {
+			return nil, err
+		}
 		switch req.Method {
-		case "ticket.query":
-			var query schema.TicketQuery
-			if err := json.Unmarshal(req.Payload, &query); err != nil {
-				writeErr(enc, err)
-				continue
-			}
-			res, err := prov.Query(ctx, query)
-			write(enc, res, err)
-		case "ticket.get":
-			var payload struct {
-				ID string `json:"id"`
-			}
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				writeErr(enc, err)
-				continue
-			}
-			res, err := prov.Get(ctx, payload.ID)
-			write(enc, res, err)
-		case "ticket.create":
-			var in schema.CreateTicketInput
-			if err := json.Unmarshal(req.Payload, &in); err != nil {
-				writeErr(enc, err)
-				continue
-			}
-			res, err := prov.Create(ctx, in)
-			write(enc, res, err)
-		case "ticket.update":
-			var payload struct {
-				ID    string                   `json:"id"`
-				Input schema.UpdateTicketInput `json:"input"`
-			}
-			if err := json.Unmarshal(req.Payload, &payload); err != nil {
-				writeErr(enc, err)
-				continue
+		case "ticket.comment.list":
+			return cm.ListComments(ctx, payload.ID)
+		case "ticket.comment.add":
+			return cm.AddComment(ctx, payload.ID, payload.Body)
+		case "ticket.comment.update":
+			return cm.UpdateComment(ctx, payload.ID, payload.CommentID, payload.Body)
+		default:
+			if err := cm.DeleteComment(ctx, payload.ID, payload.CommentID); err != nil {
+				return nil, err
 			}
-			res, err := prov.Update(ctx, payload.ID, payload.Input)
-			write(enc, res, err)
+			return map[string]bool{"deleted": true}, nil
+		}
+	case "ticket.attachment.list", "ticket.attachment.upload", "ticket.attachment.download":
+		att, ok := prov.(attacher)
+		if !ok {
+			return nil, &methodError{msg: "unsupported method: " + req.Method}
+		}
+		// Content is []byte, so it travels base64-encoded in both directions.
+		var payload struct {
+			ID           string `json:"id"`
+			AttachmentID string `json:"attachmentId"`
+			Filename     string `json:"filename"`
+			ContentType  string `json:"contentType"`
+			Content      []byte `json:"content"`
+		}
+		if err := decodePayload(req.Payload, &payload); err != nil {
+			return nil, err
+		}
+		switch req.Method {
+		case "ticket.attachment.list":
+			return att.ListAttachments(ctx, payload.ID)
+		case "ticket.attachment.upload":
+			return att.UploadAttachment(ctx, payload.ID, payload.Filename, payload.ContentType, bytes.NewReader(payload.Content))
 		default:
-			writeErr(enc, fmt.Errorf("unknown method: %s", req.Method))
+			return downloadAttachment(ctx, att, payload.AttachmentID)
 		}
+	case "ticket.outbox.status", "ticket.outbox.replay":
+		jp, ok := prov.(*adapter.JiraProvider)
+		if !ok {
+			return nil, &methodError{msg: "unsupported method: " + req.Method}
+		}
+		if req.Method == "ticket.outbox.replay" {
+			return jp.ReplayOutbox(ctx)
+		}
+		return jp.Outbox()
+	default:
+		return nil, &methodError{msg: "unknown method: " + req.Method}
 	}
 }
 
-func ensureProvider(cfg map[string]any) (coreticket.Provider, error) {
-	if provider != nil {
-		return provider, nil
-	}
-	prov, err := adapter.New(cfg)
+// downloadAttachment buffers an attachment so it can be returned inline; the
+// provider's size limit bounds how much is read.
+func downloadAttachment(ctx context.Context, att attacher, id string) (downloadedAttachment, error) {
+	meta, body, err := att.DownloadAttachment(ctx, id)
 	if err != nil {
-		return nil, err
+		return downloadedAttachment{}, err
 	}
-	provider = prov
-	return provider, nil
-}
-
-func write(enc *json.Encoder, result any, err error) {
+	defer body.Close()
+	content, err := io.ReadAll(body)
 	if err != nil {
-		writeErr(enc, err)
-		return
+		return downloadedAttachment{}, err
 	}
-	_ = enc.Encode(rpcResponse{Result: result})
-}
-
-func writeErr(enc *json.Encoder, err error) {
-	_ = enc.Encode(rpcResponse{Error: err.Error()})
+	return downloadedAttachment{Attachment: meta, Content: content}, nil
 }
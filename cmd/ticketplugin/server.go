@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	adapter "github.com/opsorch/opsorch-jira-adapter/ticket"
+)
+
+// defaultWorkers bounds how many requests run against Jira at once.
+const defaultWorkers = 8
+
+// defaultDrainTimeout bounds how long shutdown waits for in-flight requests.
+const defaultDrainTimeout = 30 * time.Second
+
+// cancelMethod cancels an in-flight request by id.
+const cancelMethod = "$/cancel"
+
+var (
+	// errCancelled is reported for requests cancelled through $/cancel.
+	errCancelled = errors.New("request cancelled")
+	// errDuplicateID rejects an id that is already in flight.
+	errDuplicateID = errors.New("duplicate request id")
+	// errShutdown is reported for requests still running when the drain
+	// deadline passes.
+	errShutdown = errors.New("plugin shutting down")
+	// errPanic is reported for requests whose handler panicked.
+	errPanic = errors.New("internal error")
+)
+
+// server reads requests from a stream and dispatches them. Requests that
+// carry an id run concurrently, up to the worker limit, and their responses
+// are written as they complete, possibly out of order. Requests without an
+// id run one at a time in arrival order, which keeps the original
+// request/response protocol intact.
+//
+// The stream starts in the legacy protocol; plugin.handshake can switch it
+// to JSON-RPC 2.0.
+type server struct {
+	out     *responseWriter
+	workers chan struct{}
+	log     *log.Logger
+	// drainTimeout is how long in-flight requests may keep running after
+	// shutdown starts before they are cancelled.
+	drainTimeout time.Duration
+	// jsonrpc is only touched by the read loop.
+	jsonrpc bool
+
+	mu       sync.Mutex
+	inflight map[string]context.CancelCauseFunc
+	wg       sync.WaitGroup
+}
+
+// outcome is the protocol-independent result of serving a request.
+type outcome struct {
+	result any
+	creds  *adapter.OAuthToken
+	err    error
+}
+
+func newServer(w io.Writer, workers int) *server {
+	if workers < 1 {
+		workers = 1
+	}
+	return &server{
+		out:          &responseWriter{enc: json.NewEncoder(w)},
+		workers:      make(chan struct{}, workers),
+		log:          log.New(os.Stderr, "ticketplugin: ", log.LstdFlags),
+		drainTimeout: defaultDrainTimeout,
+		inflight:     map[string]context.CancelCauseFunc{},
+	}
+}
+
+// run serves requests from r until EOF, a decode error or the end of ctx,
+// then waits for in-flight requests to finish. Cancelling ctx starts a
+// graceful shutdown: no further requests are read, and requests already
+// accepted keep running until they finish or drainTimeout passes, after
+// which they are cancelled with errShutdown.
+func (s *server) run(ctx context.Context, r io.Reader) error {
+	// Requests outlive ctx so a shutdown does not cut off a Jira write
+	// halfway; abort is what finally stops them.
+	reqCtx, abort := context.WithCancelCause(context.WithoutCancel(ctx))
+	defer abort(nil)
+	stopDrain := context.AfterFunc(ctx, func() {
+		s.log.Printf("shutting down: draining %d in-flight requests", s.inFlight())
+		time.AfterFunc(s.drainTimeout, func() {
+			if n := s.inFlight(); n > 0 {
+				s.log.Printf("drain timeout: cancelling %d requests", n)
+			}
+			abort(errShutdown)
+		})
+	})
+	defer stopDrain()
+	defer s.wg.Wait()
+
+	msgs := make(chan json.RawMessage)
+	errs := make(chan error, 1)
+	go s.read(ctx, r, msgs, errs)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if s.jsonrpc {
+				s.out.write(jsonrpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &jsonrpcError{Code: codeParseError, Message: err.Error()}})
+			} else {
+				s.out.write(rpcResponse{Error: err.Error()})
+			}
+			return err
+		case raw := <-msgs:
+			if s.jsonrpc || isJSONRPCHandshake(raw) {
+				s.serveJSONRPC(reqCtx, raw)
+			} else {
+				s.serveLegacy(reqCtx, raw)
+			}
+		}
+	}
+}
+
+// read decodes messages from r until it fails or ctx ends. It runs on its
+// own goroutine so a shutdown is noticed while the reader is blocked.
+func (s *server) read(ctx context.Context, r io.Reader, msgs chan<- json.RawMessage, errs chan<- error) {
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			errs <- err
+			return
+		}
+		select {
+		case msgs <- raw:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *server) serveLegacy(ctx context.Context, raw json.RawMessage) {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		s.out.write(rpcResponse{Error: err.Error()})
+		return
+	}
+
+	switch req.Method {
+	case handshakeMethod:
+		protocol, err := negotiateProtocol(req.Payload)
+		if err != nil {
+			s.out.write(rpcResponse{ID: req.ID, Error: err.Error()})
+			return
+		}
+		s.out.write(rpcResponse{ID: req.ID, Result: handshakeResult(protocol)})
+		s.jsonrpc = protocol == protocolJSONRPC
+		return
+	case cancelMethod:
+		// Handled inline so a cancellation is never queued behind the very
+		// requests it is meant to stop.
+		cancelled, err := s.cancel(req.Payload)
+		switch {
+		case req.ID == nil:
+		case err != nil:
+			s.out.write(rpcResponse{ID: req.ID, Error: err.Error()})
+		default:
+			s.out.write(rpcResponse{ID: req.ID, Result: map[string]bool{"cancelled": cancelled}})
+		}
+		return
+	}
+
+	s.submit(ctx, req, func(o outcome) {
+		resp := rpcResponse{ID: req.ID, Credentials: o.creds}
+		if o.err != nil {
+			resp.Error = o.err.Error()
+		} else {
+			resp.Result = o.result
+		}
+		s.out.write(resp)
+	})
+}
+
+// serveJSONRPC handles a single JSON-RPC message or a batch.
+func (s *server) serveJSONRPC(ctx context.Context, raw json.RawMessage) {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '[' {
+		s.serveJSONRPCItem(ctx, raw, func(resp *jsonrpcResponse) {
+			if resp != nil {
+				s.out.write(resp)
+			}
+		})
+		return
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		s.out.write(jsonrpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &jsonrpcError{Code: codeParseError, Message: err.Error()}})
+		return
+	}
+	if len(items) == 0 {
+		s.out.write(jsonrpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &jsonrpcError{Code: codeInvalidRequest, Message: "empty batch"}})
+		return
+	}
+
+	// Batch members run like individual requests; the responses are sent
+	// together, as one array, once all of them are done.
+	responses := make([]*jsonrpcResponse, len(items))
+	var batch sync.WaitGroup
+	batch.Add(len(items))
+	for i, item := range items {
+		s.serveJSONRPCItem(ctx, item, func(resp *jsonrpcResponse) {
+			responses[i] = resp
+			batch.Done()
+		})
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		batch.Wait()
+		out := make([]*jsonrpcResponse, 0, len(responses))
+		for _, resp := range responses {
+			if resp != nil {
+				out = append(out, resp)
+			}
+		}
+		// A batch of notifications gets no response at all.
+		if len(out) > 0 {
+			s.out.write(out)
+		}
+	}()
+}
+
+// serveJSONRPCItem serves one JSON-RPC request and passes its response to
+// reply, or nil for notifications.
+func (s *server) serveJSONRPCItem(ctx context.Context, raw json.RawMessage, reply func(*jsonrpcResponse)) {
+	var req jsonrpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		reply(&jsonrpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &jsonrpcError{Code: codeInvalidRequest, Message: err.Error()}})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		reply(&jsonrpcResponse{JSONRPC: "2.0", ID: responseID(req.ID), Error: &jsonrpcError{Code: codeInvalidRequest, Message: `request must have "jsonrpc": "2.0" and a method`}})
+		return
+	}
+
+	respond := func(o outcome) {
+		if o.creds != nil {
+			s.out.write(jsonrpcNotification{JSONRPC: "2.0", Method: credentialsMethod, Params: o.creds})
+		}
+		if req.ID == nil {
+			reply(nil)
+			return
+		}
+		resp := &jsonrpcResponse{JSONRPC: "2.0", ID: req.ID}
+		if o.err != nil {
+			resp.Error = toJSONRPCError(o.err)
+		} else {
+			resp.Result = o.result
+			if resp.Result == nil {
+				resp.Result = json.RawMessage("null")
+			}
+		}
+		reply(resp)
+	}
+
+	switch req.Method {
+	case handshakeMethod:
+		protocol, err := negotiateProtocol(req.Params)
+		if err == nil {
+			s.jsonrpc = protocol == protocolJSONRPC
+		}
+		respond(outcome{result: handshakeResult(protocol), err: err})
+		return
+	case cancelMethod:
+		cancelled, err := s.cancel(req.Params)
+		respond(outcome{result: map[string]bool{"cancelled": cancelled}, err: err})
+		return
+	}
+
+	var params jsonrpcParams
+	if len(req.Params) > 0 {
+		if err := decodePayload(req.Params, &params); err != nil {
+			respond(outcome{err: err})
+			return
+		}
+	}
+	s.submit(ctx, rpcRequest{
+		ID:        req.ID,
+		Method:    req.Method,
+		Config:    params.Config,
+		Payload:   params.Payload,
+		Deadline:  params.Deadline,
+		TimeoutMs: params.TimeoutMs,
+	}, respond)
+}
+
+// submit runs req and passes its outcome to reply. Requests with an id run
+// on a worker goroutine; others run inline.
+func (s *server) submit(ctx context.Context, req rpcRequest, reply func(outcome)) {
+	key := requestKey(req.ID)
+	if key == "" {
+		reply(s.handle(ctx, req, ""))
+		return
+	}
+
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	if !s.track(key, cancel) {
+		cancel(nil)
+		reply(outcome{err: fmt.Errorf("%w: %s", errDuplicateID, key)})
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		// Wait for a worker slot here rather than in the read loop so a
+		// queued request can still be cancelled.
+		select {
+		case s.workers <- struct{}{}:
+			defer func() { <-s.workers }()
+			reply(s.handle(reqCtx, req, key))
+		case <-reqCtx.Done():
+			s.untrack(key)
+			// $/cancel, the drain deadline or a disconnected HTTP client.
+			reply(outcome{err: context.Cause(reqCtx)})
+		}
+	}()
+}
+
+// handle applies the request's deadline and serves it. A panic while
+// serving is logged with its stack and reported as an error, so one bad
+// response from Jira cannot take down every other request.
+func (s *server) handle(ctx context.Context, req rpcRequest, key string) (o outcome) {
+	if key != "" {
+		defer s.untrack(key)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Printf("panic serving %s: %v\n%s", req.Method, r, debug.Stack())
+			o = outcome{err: fmt.Errorf("%w: %v", errPanic, r)}
+		}
+	}()
+
+	var cancel context.CancelFunc
+	switch {
+	case req.Deadline != nil:
+		ctx, cancel = context.WithDeadline(ctx, *req.Deadline)
+	case req.TimeoutMs > 0:
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutMs)*time.Millisecond)
+	default:
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	if result, ok, err := s.servePlugin(ctx, req); ok {
+		return outcome{result: result, err: err}
+	}
+	result, creds, err := serve(ctx, req)
+	if cause := context.Cause(ctx); err != nil && (errors.Is(cause, errCancelled) || errors.Is(cause, errShutdown)) {
+		err = cause
+	}
+	return outcome{result: result, creds: creds, err: err}
+}
+
+// cancel stops the request whose id is named in payload and reports whether
+// one was in flight.
+func (s *server) cancel(payload json.RawMessage) (bool, error) {
+	var target struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := decodePayload(payload, &target); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	cancel, ok := s.inflight[requestKey(target.ID)]
+	s.mu.Unlock()
+	if ok {
+		cancel(errCancelled)
+	}
+	return ok, nil
+}
+
+// inFlight counts requests with an id that have not completed.
+func (s *server) inFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.inflight)
+}
+
+func (s *server) track(key string, cancel context.CancelCauseFunc) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, dup := s.inflight[key]; dup {
+		return false
+	}
+	s.inflight[key] = cancel
+	return true
+}
+
+func (s *server) untrack(key string) {
+	s.mu.Lock()
+	cancel := s.inflight[key]
+	delete(s.inflight, key)
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel(nil)
+	}
+}
+
+// requestKey normalises a request id so 7 and "7" stay distinct but
+// formatting differences do not matter. Absent and null ids yield "".
+func requestKey(id json.RawMessage) string {
+	id = bytes.TrimSpace(id)
+	if len(id) == 0 || string(id) == "null" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, id); err != nil {
+		return string(id)
+	}
+	return buf.String()
+}
+
+// responseID is the id to answer with; JSON-RPC requires null when the
+// request's id is unknown.
+func responseID(id json.RawMessage) json.RawMessage {
+	if id == nil {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+// isJSONRPCHandshake reports whether a message in the legacy protocol is a
+// JSON-RPC framed plugin.handshake.
+func isJSONRPCHandshake(raw json.RawMessage) bool {
+	var probe struct {
+		JSONRPC string `json:"jsonrpc"`
+		Method  string `json:"method"`
+	}
+	return json.Unmarshal(raw, &probe) == nil && probe.JSONRPC != "" && probe.Method == handshakeMethod
+}
+
+// responseWriter serializes responses from concurrent requests onto one
+// stream so they never interleave.
+type responseWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (w *responseWriter) write(v any) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_ = w.enc.Encode(v)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opsorch/opsorch-core/schema"
+	coreticket "github.com/opsorch/opsorch-core/ticket"
+	adapter "github.com/opsorch/opsorch-jira-adapter/ticket"
+)
+
+// stubProvider serves Get from memory; the "slow" ticket blocks until its
+// context ends, "delayed" takes a moment, "missing" does not exist and
+// "panic" panics.
+type stubProvider struct{}
+
+func (stubProvider) Query(ctx context.Context, q schema.TicketQuery) ([]schema.Ticket, error) {
+	return nil, nil
+}
+
+func (stubProvider) Get(ctx context.Context, id string) (schema.Ticket, error) {
+	switch id {
+	case "slow":
+		<-ctx.Done()
+		return schema.Ticket{}, ctx.Err()
+	case "delayed":
+		time.Sleep(100 * time.Millisecond)
+	case "missing":
+		return schema.Ticket{}, adapter.ErrNotFound
+	case "panic":
+		var fields map[string]any
+		_ = fields["status"].(string)
+	}
+	return schema.Ticket{ID: id}, nil
+}
+
+func (stubProvider) Create(ctx context.Context, in schema.CreateTicketInput) (schema.Ticket, error) {
+	return schema.Ticket{Title: in.Title}, nil
+}
+
+func (stubProvider) Update(ctx context.Context, id string, in schema.UpdateTicketInput) (schema.Ticket, error) {
+	return schema.Ticket{ID: id}, nil
+}
+
+func useStubProvider(t *testing.T) {
+	t.Helper()
+	prev := providers
+	providers = newProviderCache(func(map[string]any) (coreticket.Provider, error) {
+		return stubProvider{}, nil
+	}, defaultMaxProviders)
+	t.Cleanup(func() { providers = prev })
+}
+
+type testResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+// pluginConn drives a server over pipes.
+type pluginConn struct {
+	t     *testing.T
+	in    *io.PipeWriter
+	lines chan []byte
+	done  chan struct{}
+}
+
+func startServer(t *testing.T, workers int) *pluginConn {
+	return startServerContext(t, context.Background(), workers, defaultDrainTimeout)
+}
+
+// startServerContext starts a server that shuts down when ctx ends.
+func startServerContext(t *testing.T, ctx context.Context, workers int, drainTimeout time.Duration) *pluginConn {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &pluginConn{t: t, in: inW, lines: make(chan []byte, 16), done: make(chan struct{})}
+	srv := newServer(outW, workers)
+	srv.log = log.New(io.Discard, "", 0)
+	srv.drainTimeout = drainTimeout
+	go func() {
+		srv.run(ctx, inR)
+		outW.Close()
+		close(c.done)
+	}()
+
+	// Drain responses concurrently so the server never blocks on the pipe
+	// while the test is still writing requests.
+	go func() {
+		defer close(c.lines)
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			c.lines <- append([]byte(nil), scanner.Bytes()...)
+		}
+	}()
+	return c
+}
+
+func (c *pluginConn) send(line string) {
+	if _, err := io.WriteString(c.in, line+"\n"); err != nil {
+		c.t.Fatalf("write request: %v", err)
+	}
+}
+
+func (c *pluginConn) recvRaw() []byte {
+	select {
+	case line, ok := <-c.lines:
+		if !ok {
+			c.t.Fatal("no response")
+		}
+		return line
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for a response")
+	}
+	return nil
+}
+
+func (c *pluginConn) recv() testResponse {
+	line := c.recvRaw()
+	var resp testResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		c.t.Fatalf("decode response %s: %v", line, err)
+	}
+	return resp
+}
+
+func (c *pluginConn) stop() {
+	c.in.Close()
+	<-c.done
+}
+
+func TestServerConcurrency(t *testing.T) {
+	useStubProvider(t)
+
+	t.Run("requests without id keep the original protocol", func(t *testing.T) {
+		c := startServer(t, 2)
+		defer c.stop()
+
+		c.send(`{"method":"ticket.get","payload":{"id":"PROJ-1"}}`)
+		resp := c.recv()
+		if resp.ID != nil || resp.Error != "" || !strings.Contains(string(resp.Result), `"PROJ-1"`) {
+			t.Errorf("response = %+v", resp)
+		}
+	})
+
+	t.Run("responses are written out of order", func(t *testing.T) {
+		c := startServer(t, 2)
+		defer c.stop()
+
+		c.send(`{"id":1,"method":"ticket.get","payload":{"id":"slow"},"timeoutMs":200}`)
+		c.send(`{"id":"two","method":"ticket.get","payload":{"id":"PROJ-2"}}`)
+
+		first := c.recv()
+		if string(first.ID) != `"two"` || first.Error != "" {
+			t.Errorf("first response = %+v, want the fast request", first)
+		}
+		second := c.recv()
+		if string(second.ID) != "1" || !strings.Contains(second.Error, "deadline exceeded") {
+			t.Errorf("second response = %+v, want a deadline error", second)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		c := startServer(t, 1)
+		defer c.stop()
+
+		c.send(`{"id":1,"method":"ticket.get","payload":{"id":"slow"}}`)
+		// Queued behind request 1 on the only worker.
+		c.send(`{"id":2,"method":"ticket.get","payload":{"id":"slow"}}`)
+		time.Sleep(20 * time.Millisecond)
+		c.send(`{"id":3,"method":"$/cancel","payload":{"id":2}}`)
+		c.send(`{"method":"$/cancel","payload":{"id":1}}`)
+
+		got := map[string]testResponse{}
+		for i := 0; i < 3; i++ {
+			resp := c.recv()
+			got[string(resp.ID)] = resp
+		}
+		if string(got["3"].Result) != `{"cancelled":true}` {
+			t.Errorf("cancel response = %+v", got["3"])
+		}
+		for _, id := range []string{"1", "2"} {
+			if got[id].Error != errCancelled.Error() {
+				t.Errorf("request %s = %+v, want cancelled", id, got[id])
+			}
+		}
+	})
+
+	t.Run("duplicate ids are rejected", func(t *testing.T) {
+		c := startServer(t, 2)
+		defer c.stop()
+
+		c.send(`{"id":7,"method":"ticket.get","payload":{"id":"slow"}}`)
+		c.send(`{"id":7,"method":"ticket.get","payload":{"id":"PROJ-1"}}`)
+		if resp := c.recv(); string(resp.ID) != "7" || !strings.Contains(resp.Error, "duplicate request id") {
+			t.Errorf("response = %+v, want duplicate id error", resp)
+		}
+		c.send(`{"method":"$/cancel","payload":{"id":7}}`)
+		c.recv()
+	})
+}
+
+func TestServerPanicRecovery(t *testing.T) {
+	useStubProvider(t)
+	c := startServer(t, 2)
+	defer c.stop()
+
+	c.send(`{"id":1,"method":"ticket.get","payload":{"id":"panic"}}`)
+	if resp := c.recv(); !strings.HasPrefix(resp.Error, "internal error: ") {
+		t.Errorf("response = %+v, want an internal error", resp)
+	}
+	c.send(`{"method":"ticket.get","payload":{"id":"panic"}}`)
+	if resp := c.recv(); !strings.HasPrefix(resp.Error, "internal error: ") {
+		t.Errorf("response = %+v, want an internal error", resp)
+	}
+
+	// The process survives and keeps serving.
+	c.send(`{"method":"ticket.get","payload":{"id":"PROJ-1"}}`)
+	if resp := c.recv(); resp.Error != "" {
+		t.Errorf("response after panic = %+v", resp)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	useStubProvider(t)
+
+	waitDone := func(t *testing.T, c *pluginConn) {
+		t.Helper()
+		select {
+		case <-c.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("server did not exit after shutdown")
+		}
+	}
+
+	t.Run("drains in-flight requests", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		c := startServerContext(t, ctx, 2, 5*time.Second)
+		defer c.stop()
+
+		c.send(`{"id":1,"method":"ticket.get","payload":{"id":"delayed"}}`)
+		// An id-less request answers only once everything before it was read.
+		c.send(`{"method":"ticket.get","payload":{"id":"PROJ-1"}}`)
+		c.recv()
+		cancel()
+
+		if resp := c.recv(); string(resp.ID) != "1" || resp.Error != "" {
+			t.Errorf("response = %+v, want request 1 to complete", resp)
+		}
+		waitDone(t, c)
+	})
+
+	t.Run("cancels requests at the drain deadline", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		c := startServerContext(t, ctx, 1, 50*time.Millisecond)
+		defer c.stop()
+
+		c.send(`{"id":1,"method":"ticket.get","payload":{"id":"slow"}}`)
+		// Queued behind request 1 on the only worker.
+		c.send(`{"id":2,"method":"ticket.get","payload":{"id":"slow"}}`)
+		c.send(`{"method":"plugin.capabilities"}`)
+		c.recv()
+		cancel()
+
+		for i := 0; i < 2; i++ {
+			if resp := c.recv(); resp.Error != errShutdown.Error() {
+				t.Errorf("response = %+v, want %q", resp, errShutdown)
+			}
+		}
+		waitDone(t, c)
+	})
+}
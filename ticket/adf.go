@@ -0,0 +1,394 @@
+package ticket
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adfNode is one node of an Atlassian Document Format tree. Block nodes
+// carry Content, text nodes carry Text and Marks.
+type adfNode struct {
+	Type    string         `json:"type,omitempty"`
+	Text    string         `json:"text,omitempty"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	Marks   []adfMark      `json:"marks,omitempty"`
+	Content []adfNode      `json:"content,omitempty"`
+}
+
+type adfMark struct {
+	Type  string         `json:"type"`
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// renderADFMarkdown renders an ADF document as CommonMark with GitHub-style
+// tables, strikethrough and task lists.
+func renderADFMarkdown(doc adfNode) string {
+	return strings.TrimSpace(adfRenderer{markdown: true}.block(doc))
+}
+
+// renderADFText renders an ADF document as readable plain text: structure is
+// kept through line breaks and list bullets, but no Markdown syntax is used.
+func renderADFText(doc adfNode) string {
+	return strings.TrimSpace(adfRenderer{}.block(doc))
+}
+
+type adfRenderer struct {
+	markdown bool
+}
+
+func (r adfRenderer) blocks(nodes []adfNode, sep string) string {
+	parts := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		if s := r.block(n); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+func (r adfRenderer) block(n adfNode) string {
+	switch n.Type {
+	case "doc":
+		return r.blocks(n.Content, "\n\n")
+	case "paragraph":
+		return r.inline(n.Content)
+	case "heading":
+		text := r.inline(n.Content)
+		if !r.markdown || text == "" {
+			return text
+		}
+		level := attrInt(n.Attrs, "level", 1)
+		if level < 1 {
+			level = 1
+		} else if level > 6 {
+			level = 6
+		}
+		return strings.Repeat("#", level) + " " + text
+	case "bulletList", "orderedList", "taskList", "decisionList":
+		return r.list(n)
+	case "codeBlock":
+		return r.codeBlock(n)
+	case "blockquote":
+		return r.quote(r.blocks(n.Content, "\n\n"))
+	case "panel":
+		body := r.blocks(n.Content, "\n\n")
+		if label := attrString(n.Attrs, "panelType"); label != "" && r.markdown {
+			body = "**" + strings.ToUpper(label[:1]) + label[1:] + ":** " + body
+		}
+		return r.quote(body)
+	case "rule":
+		return "---"
+	case "table":
+		return r.table(n)
+	case "expand", "nestedExpand":
+		body := r.blocks(n.Content, "\n\n")
+		title := attrString(n.Attrs, "title")
+		if title == "" {
+			return body
+		}
+		if r.markdown {
+			title = "**" + title + "**"
+		}
+		return strings.TrimSpace(title + "\n\n" + body)
+	case "mediaSingle", "mediaGroup":
+		return r.blocks(n.Content, "\n")
+	case "media":
+		return r.media(n)
+	case "blockCard", "embedCard":
+		return r.card(n)
+	}
+
+	if isInlineNode(n) {
+		return r.inlineNode(n)
+	}
+	// Unknown or untyped container: render inline when it only wraps inline
+	// nodes (a paragraph in all but name), otherwise as nested blocks.
+	for _, child := range n.Content {
+		if !isInlineNode(child) {
+			return r.blocks(n.Content, "\n\n")
+		}
+	}
+	return r.inline(n.Content)
+}
+
+func isInlineNode(n adfNode) bool {
+	switch n.Type {
+	case "text", "hardBreak", "mention", "emoji", "inlineCard", "status", "date", "placeholder", "mediaInline", "inlineExtension":
+		return true
+	case "":
+		return n.Text != ""
+	}
+	return false
+}
+
+func (r adfRenderer) inline(nodes []adfNode) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(r.inlineNode(n))
+	}
+	return b.String()
+}
+
+func (r adfRenderer) inlineNode(n adfNode) string {
+	switch n.Type {
+	case "text", "":
+		if n.Text == "" && len(n.Content) > 0 {
+			return r.inline(n.Content)
+		}
+		return r.marks(n.Text, n.Marks)
+	case "hardBreak":
+		if r.markdown {
+			return "  \n"
+		}
+		return "\n"
+	case "mention":
+		text := attrString(n.Attrs, "text")
+		if text == "" {
+			text = attrString(n.Attrs, "id")
+		}
+		if text != "" && !strings.HasPrefix(text, "@") {
+			text = "@" + text
+		}
+		return text
+	case "emoji":
+		if text := attrString(n.Attrs, "text"); text != "" {
+			return text
+		}
+		return attrString(n.Attrs, "shortName")
+	case "inlineCard":
+		return r.card(n)
+	case "status":
+		if text := attrString(n.Attrs, "text"); text != "" {
+			return "[" + text + "]"
+		}
+		return ""
+	case "date":
+		ts := attrString(n.Attrs, "timestamp")
+		if ms, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			return time.UnixMilli(ms).UTC().Format("2006-01-02")
+		}
+		return ts
+	case "placeholder":
+		return attrString(n.Attrs, "text")
+	case "mediaInline":
+		return r.media(n)
+	}
+	return r.inline(n.Content)
+}
+
+// marks applies text formatting. Markdown delimiters must hug the text, so
+// surrounding whitespace is moved outside them.
+func (r adfRenderer) marks(text string, marks []adfMark) string {
+	if text == "" || len(marks) == 0 {
+		return text
+	}
+
+	var href string
+	for _, m := range marks {
+		if m.Type == "link" {
+			href = attrString(m.Attrs, "href")
+		}
+	}
+
+	if !r.markdown {
+		if href != "" && href != text {
+			return text + " (" + href + ")"
+		}
+		return text
+	}
+
+	lead := text[:len(text)-len(strings.TrimLeft(text, " "))]
+	trail := text[len(strings.TrimRight(text, " ")):]
+	core := strings.TrimSpace(text)
+	if core == "" {
+		return text
+	}
+
+	for _, m := range marks {
+		switch m.Type {
+		case "code":
+			fence := "`"
+			if strings.Contains(core, "`") {
+				fence = "``"
+				core = " " + core + " "
+			}
+			core = fence + core + fence
+		case "strong":
+			core = "**" + core + "**"
+		case "em":
+			core = "*" + core + "*"
+		case "strike":
+			core = "~~" + core + "~~"
+		}
+	}
+	if href != "" {
+		core = "[" + core + "](" + href + ")"
+	}
+	return lead + core + trail
+}
+
+func (r adfRenderer) list(n adfNode) string {
+	start := attrInt(n.Attrs, "order", 1)
+	items := make([]string, 0, len(n.Content))
+	for i, item := range n.Content {
+		var marker string
+		switch {
+		case n.Type == "orderedList":
+			marker = fmt.Sprintf("%d. ", start+i)
+		case item.Type == "taskItem" && r.markdown:
+			marker = "- [ ] "
+			if attrString(item.Attrs, "state") == "DONE" {
+				marker = "- [x] "
+			}
+		default:
+			marker = "- "
+		}
+
+		var body string
+		if item.Type == "taskItem" || item.Type == "decisionItem" {
+			body = r.inline(item.Content)
+		} else {
+			body = r.blocks(item.Content, "\n")
+		}
+		items = append(items, marker+indent(body, len(marker)))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (r adfRenderer) codeBlock(n adfNode) string {
+	var b strings.Builder
+	for _, child := range n.Content {
+		b.WriteString(child.Text)
+	}
+	code := strings.TrimRight(b.String(), "\n")
+	if !r.markdown {
+		return code
+	}
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + attrString(n.Attrs, "language") + "\n" + code + "\n" + fence
+}
+
+func (r adfRenderer) quote(body string) string {
+	if !r.markdown || body == "" {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r adfRenderer) table(n adfNode) string {
+	var rows [][]string
+	width := 0
+	for _, row := range n.Content {
+		var cells []string
+		for _, cell := range row.Content {
+			text := r.blocks(cell.Content, "\n")
+			if r.markdown {
+				text = strings.ReplaceAll(text, "|", `\|`)
+				text = strings.ReplaceAll(text, "  \n", "<br>")
+				text = strings.ReplaceAll(text, "\n", "<br>")
+			} else {
+				text = strings.Join(strings.Fields(text), " ")
+			}
+			cells = append(cells, text)
+		}
+		if len(cells) > width {
+			width = len(cells)
+		}
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for i, cells := range rows {
+		for len(cells) < width {
+			cells = append(cells, "")
+		}
+		if !r.markdown {
+			lines = append(lines, strings.Join(cells, " | "))
+			continue
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			// Markdown tables always need a header row; Jira's first row
+			// serves as one whether or not it uses tableHeader cells.
+			lines = append(lines, "|"+strings.Repeat(" --- |", width))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r adfRenderer) media(n adfNode) string {
+	name := attrString(n.Attrs, "alt")
+	if name == "" {
+		name = attrString(n.Attrs, "id")
+	}
+	if url := attrString(n.Attrs, "url"); url != "" {
+		if r.markdown {
+			return "![" + name + "](" + url + ")"
+		}
+		return url
+	}
+	if name == "" {
+		return ""
+	}
+	return "[attachment: " + name + "]"
+}
+
+func (r adfRenderer) card(n adfNode) string {
+	url := attrString(n.Attrs, "url")
+	if url == "" {
+		return ""
+	}
+	if r.markdown {
+		return "<" + url + ">"
+	}
+	return url
+}
+
+// indent prefixes every line after the first with n spaces so continuation
+// lines stay inside their list item.
+func indent(s string, n int) string {
+	if !strings.Contains(s, "\n") {
+		return s
+	}
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = pad + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func attrString(attrs map[string]any, key string) string {
+	switch v := attrs[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+func attrInt(attrs map[string]any, key string, def int) int {
+	if v, ok := intValue(attrs[key]); ok {
+		return v
+	}
+	return def
+}
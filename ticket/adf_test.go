@@ -0,0 +1,109 @@
+package ticket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const runbookADF = `{
+  "type": "doc",
+  "version": 1,
+  "content": [
+    {"type": "heading", "attrs": {"level": 2}, "content": [{"type": "text", "text": "Runbook"}]},
+    {"type": "paragraph", "content": [
+      {"type": "text", "text": "Paged "},
+      {"type": "mention", "attrs": {"id": "abc", "text": "@Alice"}},
+      {"type": "text", "text": " about "},
+      {"type": "text", "text": "disk usage ", "marks": [{"type": "strong"}]},
+      {"type": "text", "text": "see dashboard", "marks": [{"type": "link", "attrs": {"href": "https://grafana.example.com/d/1"}}]},
+      {"type": "hardBreak"},
+      {"type": "text", "text": "df -h", "marks": [{"type": "code"}]}
+    ]},
+    {"type": "bulletList", "content": [
+      {"type": "listItem", "content": [
+        {"type": "paragraph", "content": [{"type": "text", "text": "Check volumes"}]},
+        {"type": "orderedList", "attrs": {"order": 3}, "content": [
+          {"type": "listItem", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "root"}]}]},
+          {"type": "listItem", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "data"}]}]}
+        ]}
+      ]},
+      {"type": "listItem", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "Rotate logs", "marks": [{"type": "em"}]}]}]}
+    ]},
+    {"type": "codeBlock", "attrs": {"language": "bash"}, "content": [{"type": "text", "text": "sudo journalctl --vacuum-size=1G"}]},
+    {"type": "table", "content": [
+      {"type": "tableRow", "content": [
+        {"type": "tableHeader", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "Host"}]}]},
+        {"type": "tableHeader", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "Usage"}]}]}
+      ]},
+      {"type": "tableRow", "content": [
+        {"type": "tableCell", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "db-1"}]}]},
+        {"type": "tableCell", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "97%"}]}]}
+      ]}
+    ]},
+    {"type": "blockquote", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "Escalate after 30m"}]}]},
+    {"type": "taskList", "content": [
+      {"type": "taskItem", "attrs": {"state": "DONE"}, "content": [{"type": "text", "text": "ack"}]},
+      {"type": "taskItem", "attrs": {"state": "TODO"}, "content": [{"type": "text", "text": "resolve"}]}
+    ]},
+    {"type": "rule"}
+  ]
+}`
+
+func TestRenderADF(t *testing.T) {
+	var doc adfNode
+	if err := json.Unmarshal([]byte(runbookADF), &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	wantMarkdown := "## Runbook\n\n" +
+		"Paged @Alice about **disk usage** [see dashboard](https://grafana.example.com/d/1)  \n`df -h`\n\n" +
+		"- Check volumes\n  3. root\n  4. data\n- *Rotate logs*\n\n" +
+		"```bash\nsudo journalctl --vacuum-size=1G\n```\n\n" +
+		"| Host | Usage |\n| --- | --- |\n| db-1 | 97% |\n\n" +
+		"> Escalate after 30m\n\n" +
+		"- [x] ack\n- [ ] resolve\n\n" +
+		"---"
+	if got := renderADFMarkdown(doc); got != wantMarkdown {
+		t.Errorf("renderADFMarkdown() =\n%s\nwant\n%s", got, wantMarkdown)
+	}
+
+	wantText := "Runbook\n\n" +
+		"Paged @Alice about disk usage see dashboard (https://grafana.example.com/d/1)\ndf -h\n\n" +
+		"- Check volumes\n  3. root\n  4. data\n- Rotate logs\n\n" +
+		"sudo journalctl --vacuum-size=1G\n\n" +
+		"Host | Usage\ndb-1 | 97%\n\n" +
+		"Escalate after 30m\n\n" +
+		"- ack\n- resolve\n\n" +
+		"---"
+	if got := renderADFText(doc); got != wantText {
+		t.Errorf("renderADFText() =\n%s\nwant\n%s", got, wantText)
+	}
+}
+
+func TestRenderADFInlineNodes(t *testing.T) {
+	tests := []struct {
+		name string
+		node string
+		want string
+	}{
+		{name: "emoji", node: `{"type":"emoji","attrs":{"shortName":":fire:","text":"🔥"}}`, want: "🔥"},
+		{name: "status", node: `{"type":"status","attrs":{"text":"BLOCKED","color":"red"}}`, want: "[BLOCKED]"},
+		{name: "date", node: `{"type":"date","attrs":{"timestamp":"1732183200000"}}`, want: "2024-11-21"},
+		{name: "inline card", node: `{"type":"inlineCard","attrs":{"url":"https://example.com/x"}}`, want: "<https://example.com/x>"},
+		{name: "mention without text", node: `{"type":"mention","attrs":{"id":"712020:abc"}}`, want: "@712020:abc"},
+		{name: "code with backtick", node: "{\"type\":\"text\",\"text\":\"a`b\",\"marks\":[{\"type\":\"code\"}]}", want: "`` a`b ``"},
+		{name: "strike", node: `{"type":"text","text":"old","marks":[{"type":"strike"}]}`, want: "~~old~~"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n adfNode
+			if err := json.Unmarshal([]byte(tt.node), &n); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			doc := adfNode{Type: "doc", Content: []adfNode{{Type: "paragraph", Content: []adfNode{n}}}}
+			if got := renderADFMarkdown(doc); got != tt.want {
+				t.Errorf("renderADFMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
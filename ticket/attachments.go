@@ -0,0 +1,273 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Supported values for Config.AttachmentMIMEDetection.
+const (
+	// MIMEDetectAuto uses the file extension, falling back to sniffing the
+	// content.
+	MIMEDetectAuto = "auto"
+	// MIMEDetectExtension only consults the file extension.
+	MIMEDetectExtension = "extension"
+	// MIMEDetectContent only sniffs the first 512 bytes of content.
+	MIMEDetectContent = "content"
+	// MIMEDetectOff sends application/octet-stream unless the caller names a
+	// type.
+	MIMEDetectOff = "off"
+)
+
+// defaultMaxAttachmentSize matches Jira Cloud's default per-file limit.
+const defaultMaxAttachmentSize = 10 << 20
+
+// ErrAttachmentTooLarge is returned when an attachment exceeds
+// Config.MaxAttachmentSize.
+var ErrAttachmentTooLarge error = &kindError{code: "bad_request", msg: "attachment exceeds size limit"}
+
+// Attachment describes a file attached to a Jira issue.
+type Attachment struct {
+	ID         string    `json:"id"`
+	Filename   string    `json:"filename"`
+	MIMEType   string    `json:"mimeType,omitempty"`
+	Size       int64     `json:"size"`
+	Author     string    `json:"author,omitempty"`
+	AuthorName string    `json:"authorName,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// URL is Jira's content URL; fetching it requires the same credentials.
+	URL string `json:"url,omitempty"`
+}
+
+// jiraAttachment represents attachment metadata from the Jira API.
+type jiraAttachment struct {
+	ID       string    `json:"id"`
+	Filename string    `json:"filename"`
+	MIMEType string    `json:"mimeType"`
+	Size     int64     `json:"size"`
+	Author   *jiraUser `json:"author"`
+	Created  string    `json:"created"`
+	Content  string    `json:"content"`
+}
+
+// ListAttachments returns the metadata of every file attached to an issue.
+func (p *JiraProvider) ListAttachments(ctx context.Context, issueID string) ([]Attachment, error) {
+	resp, err := p.do(ctx, apiRequest{method: http.MethodGet, path: p.apiPath("/issue/" + issueID + "?fields=attachment")})
+	if err != nil {
+		return nil, err
+	}
+
+	var issue struct {
+		Fields struct {
+			Attachment []jiraAttachment `json:"attachment"`
+		} `json:"fields"`
+	}
+	if err := decodeResponse(resp, http.StatusOK, &issue); err != nil {
+		return nil, err
+	}
+	return convertJiraAttachments(issue.Fields.Attachment), nil
+}
+
+// UploadAttachment attaches the content of r to an issue under filename.
+// contentType may be empty, in which case it is detected according to
+// Config.AttachmentMIMEDetection. Content larger than MaxAttachmentSize is
+// rejected before anything is sent.
+func (p *JiraProvider) UploadAttachment(ctx context.Context, issueID, filename, contentType string, r io.Reader) (Attachment, error) {
+	if filename == "" {
+		return Attachment{}, fmt.Errorf("%w: attachment filename is required", ErrValidation)
+	}
+
+	content, err := p.readAttachment(r)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if contentType == "" {
+		contentType = p.detectMIMEType(filename, content)
+	}
+	if !p.attachmentTypeAllowed(contentType) {
+		return Attachment{}, fmt.Errorf("%w: attachment type %q is not allowed", ErrValidation, contentType)
+	}
+
+	// The whole form is buffered so a throttled upload can be replayed; the
+	// size limit bounds how much that can be.
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {mime.FormatMediaType("form-data", map[string]string{"name": "file", "filename": filepath.Base(filename)})},
+		"Content-Type":        {contentType},
+	})
+	if err != nil {
+		return Attachment{}, fmt.Errorf("encode attachment: %w", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		return Attachment{}, fmt.Errorf("encode attachment: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return Attachment{}, fmt.Errorf("encode attachment: %w", err)
+	}
+
+	resp, err := p.do(ctx, apiRequest{
+		method:      http.MethodPost,
+		path:        p.apiPath("/issue/" + issueID + "/attachments"),
+		rawBody:     body.Bytes(),
+		contentType: form.FormDataContentType(),
+		// Jira rejects multipart posts without this XSRF opt-out.
+		header: http.Header{"X-Atlassian-Token": {"no-check"}},
+	})
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	var created []jiraAttachment
+	if err := decodeResponse(resp, http.StatusOK, &created); err != nil {
+		return Attachment{}, err
+	}
+	if len(created) == 0 {
+		return Attachment{}, errors.New("jira returned no attachment for the upload")
+	}
+	return convertJiraAttachment(created[0]), nil
+}
+
+// DownloadAttachment streams the content of an attachment. The caller must
+// close the returned reader, which fails with ErrAttachmentTooLarge if the
+// content turns out to exceed MaxAttachmentSize.
+func (p *JiraProvider) DownloadAttachment(ctx context.Context, attachmentID string) (Attachment, io.ReadCloser, error) {
+	resp, err := p.do(ctx, apiRequest{method: http.MethodGet, path: p.apiPath("/attachment/" + attachmentID)})
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+	var meta jiraAttachment
+	if err := decodeResponse(resp, http.StatusOK, &meta); err != nil {
+		return Attachment{}, nil, err
+	}
+	att := convertJiraAttachment(meta)
+	if limit := p.cfg.MaxAttachmentSize; limit > 0 && att.Size > limit {
+		return Attachment{}, nil, fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrAttachmentTooLarge, att.Filename, att.Size, limit)
+	}
+
+	resp, err = p.do(ctx, apiRequest{
+		method: http.MethodGet,
+		path:   p.apiPath("/attachment/content/" + attachmentID),
+		header: http.Header{"Accept": {"*/*"}},
+	})
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return Attachment{}, nil, newAPIError(resp)
+	}
+
+	body := resp.Body
+	if limit := p.cfg.MaxAttachmentSize; limit > 0 {
+		body = &limitedBody{ReadCloser: resp.Body, remaining: limit}
+	}
+	return att, body, nil
+}
+
+// readAttachment reads upload content, enforcing MaxAttachmentSize.
+func (p *JiraProvider) readAttachment(r io.Reader) ([]byte, error) {
+	limit := p.cfg.MaxAttachmentSize
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read attachment: %w", err)
+	}
+	if limit > 0 && int64(len(content)) > limit {
+		return nil, fmt.Errorf("%w: limit is %d bytes", ErrAttachmentTooLarge, limit)
+	}
+	return content, nil
+}
+
+func (p *JiraProvider) detectMIMEType(filename string, content []byte) string {
+	mode := p.cfg.AttachmentMIMEDetection
+	if mode == "" {
+		mode = MIMEDetectAuto
+	}
+	if mode == MIMEDetectAuto || mode == MIMEDetectExtension {
+		if t := mime.TypeByExtension(filepath.Ext(filename)); t != "" {
+			return t
+		}
+	}
+	if mode == MIMEDetectAuto || mode == MIMEDetectContent {
+		return http.DetectContentType(content)
+	}
+	return "application/octet-stream"
+}
+
+func (p *JiraProvider) attachmentTypeAllowed(contentType string) bool {
+	if len(p.cfg.AllowedAttachmentTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range p.cfg.AllowedAttachmentTypes {
+		allowed = strings.ToLower(allowed)
+		if allowed == mediaType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedBody fails the read once more than remaining bytes arrive, so a
+// download cannot exceed the limit even if Jira's metadata understated it.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(buf []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrAttachmentTooLarge
+	}
+	if int64(len(buf)) > b.remaining+1 {
+		buf = buf[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(buf)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), ErrAttachmentTooLarge
+	}
+	return n, err
+}
+
+func convertJiraAttachments(in []jiraAttachment) []Attachment {
+	out := make([]Attachment, len(in))
+	for i, a := range in {
+		out[i] = convertJiraAttachment(a)
+	}
+	return out
+}
+
+func convertJiraAttachment(a jiraAttachment) Attachment {
+	att := Attachment{
+		ID:       a.ID,
+		Filename: a.Filename,
+		MIMEType: a.MIMEType,
+		Size:     a.Size,
+		URL:      a.Content,
+	}
+	if a.Author != nil {
+		att.Author = a.Author.ID()
+		att.AuthorName = a.Author.DisplayName
+	}
+	if createdAt, ok := parseJiraTime(a.Created); ok {
+		att.CreatedAt = createdAt
+	}
+	return att
+}
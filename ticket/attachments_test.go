@@ -0,0 +1,148 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAttachments(t *testing.T) {
+	var uploaded struct {
+		token, filename, contentType, content string
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1/attachments" && r.Method == "POST":
+			uploaded.token = r.Header.Get("X-Atlassian-Token")
+			file, header, err := r.FormFile("file")
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			content, _ := io.ReadAll(file)
+			uploaded.filename = header.Filename
+			uploaded.contentType = header.Header.Get("Content-Type")
+			uploaded.content = string(content)
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"id": "200", "filename": header.Filename, "mimeType": uploaded.contentType, "size": len(content), "created": "2024-01-01T10:00:00Z"},
+			})
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1" && r.URL.Query().Get("fields") == "attachment":
+			json.NewEncoder(w).Encode(map[string]any{"fields": map[string]any{"attachment": []map[string]any{
+				{"id": "200", "filename": "app.log", "mimeType": "text/plain", "size": 11, "author": map[string]any{"accountId": "acc-1", "displayName": "Alice"}, "content": "https://example.atlassian.net/rest/api/3/attachment/content/200"},
+				{"id": "201", "filename": "heap.hprof", "mimeType": "application/octet-stream", "size": 1 << 30},
+			}}})
+		case r.URL.Path == "/rest/api/3/attachment/200":
+			json.NewEncoder(w).Encode(map[string]any{"id": "200", "filename": "app.log", "mimeType": "text/plain", "size": 11})
+		case r.URL.Path == "/rest/api/3/attachment/201":
+			json.NewEncoder(w).Encode(map[string]any{"id": "201", "filename": "heap.hprof", "size": 1 << 30})
+		case r.URL.Path == "/rest/api/3/attachment/202":
+			// Metadata understates the real size.
+			json.NewEncoder(w).Encode(map[string]any{"id": "202", "filename": "liar.bin", "size": 1})
+		case r.URL.Path == "/rest/api/3/attachment/content/200":
+			io.WriteString(w, "ERROR boom\n")
+		case r.URL.Path == "/rest/api/3/attachment/content/202":
+			io.WriteString(w, strings.Repeat("x", 64))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{
+		cfg:    Config{Source: "jira", APIURL: server.URL, ProjectKey: "PROJ", MaxAttachmentSize: 32},
+		client: &http.Client{},
+	}
+	ctx := context.Background()
+
+	t.Run("upload detects type from extension", func(t *testing.T) {
+		att, err := p.UploadAttachment(ctx, "PROJ-1", "dashboard.png", "", strings.NewReader("not really a png"))
+		if err != nil {
+			t.Fatalf("UploadAttachment() error = %v", err)
+		}
+		if uploaded.token != "no-check" {
+			t.Errorf("X-Atlassian-Token = %q, want no-check", uploaded.token)
+		}
+		if uploaded.filename != "dashboard.png" || uploaded.contentType != "image/png" || uploaded.content != "not really a png" {
+			t.Errorf("uploaded = %+v", uploaded)
+		}
+		if att.ID != "200" || att.Size != 16 {
+			t.Errorf("attachment = %+v", att)
+		}
+	})
+
+	t.Run("upload sniffs content without a known extension", func(t *testing.T) {
+		if _, err := p.UploadAttachment(ctx, "PROJ-1", "excerpt", "", strings.NewReader("plain log line")); err != nil {
+			t.Fatalf("UploadAttachment() error = %v", err)
+		}
+		if uploaded.contentType != "text/plain; charset=utf-8" {
+			t.Errorf("content type = %q", uploaded.contentType)
+		}
+	})
+
+	t.Run("upload over the limit is rejected locally", func(t *testing.T) {
+		uploaded.content = ""
+		_, err := p.UploadAttachment(ctx, "PROJ-1", "big.log", "", strings.NewReader(strings.Repeat("x", 33)))
+		if !errors.Is(err, ErrAttachmentTooLarge) {
+			t.Errorf("error = %v, want ErrAttachmentTooLarge", err)
+		}
+		if uploaded.content != "" {
+			t.Error("oversized attachment was sent")
+		}
+	})
+
+	t.Run("upload type allowlist", func(t *testing.T) {
+		restricted := &JiraProvider{cfg: p.cfg, client: p.client}
+		restricted.cfg.AllowedAttachmentTypes = []string{"image/*", "text/plain"}
+		if _, err := restricted.UploadAttachment(ctx, "PROJ-1", "shot.jpg", "", strings.NewReader("x")); err != nil {
+			t.Errorf("image/jpeg rejected: %v", err)
+		}
+		if _, err := restricted.UploadAttachment(ctx, "PROJ-1", "dump.zip", "", strings.NewReader("x")); !errors.Is(err, ErrValidation) {
+			t.Errorf("error = %v, want ErrValidation", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		atts, err := p.ListAttachments(ctx, "PROJ-1")
+		if err != nil {
+			t.Fatalf("ListAttachments() error = %v", err)
+		}
+		if len(atts) != 2 || atts[0].Filename != "app.log" || atts[0].Author != "acc-1" || atts[0].URL == "" {
+			t.Errorf("attachments = %+v", atts)
+		}
+	})
+
+	t.Run("download streams content", func(t *testing.T) {
+		att, body, err := p.DownloadAttachment(ctx, "200")
+		if err != nil {
+			t.Fatalf("DownloadAttachment() error = %v", err)
+		}
+		defer body.Close()
+		content, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+		if att.Filename != "app.log" || string(content) != "ERROR boom\n" {
+			t.Errorf("attachment = %+v, content = %q", att, content)
+		}
+	})
+
+	t.Run("download over the limit", func(t *testing.T) {
+		if _, _, err := p.DownloadAttachment(ctx, "201"); !errors.Is(err, ErrAttachmentTooLarge) {
+			t.Errorf("error = %v, want ErrAttachmentTooLarge", err)
+		}
+		_, body, err := p.DownloadAttachment(ctx, "202")
+		if err != nil {
+			t.Fatalf("DownloadAttachment() error = %v", err)
+		}
+		defer body.Close()
+		content, err := io.ReadAll(body)
+		if !errors.Is(err, ErrAttachmentTooLarge) || len(content) != 32 {
+			t.Errorf("read %d bytes, error = %v; want 32 bytes and ErrAttachmentTooLarge", len(content), err)
+		}
+	})
+}
@@ -0,0 +1,317 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported values for Config.Auth.
+const (
+	// AuthBasic sends Email and APIToken as HTTP basic auth (Cloud default).
+	AuthBasic = "basic"
+	// AuthBearer sends APIToken as a bearer token: a Data Center Personal
+	// Access Token or a Cloud service-account API token (Data Center default).
+	AuthBearer = "bearer"
+	// AuthOAuth2 uses OAuth 2.0 (3LO) access tokens obtained from an
+	// authorization code or refresh token, refreshed automatically.
+	AuthOAuth2 = "oauth2"
+	// AuthClientCredentials uses the OAuth 2.0 client-credentials grant of an
+	// Atlassian service account.
+	AuthClientCredentials = "clientCredentials"
+)
+
+// Defaults for OAuth-based authentication on Jira Cloud.
+const (
+	defaultTokenURL   = "https://auth.atlassian.com/oauth/token"
+	defaultGatewayURL = "https://api.atlassian.com"
+	// tokenExpirySkew refreshes tokens this long before they actually expire
+	// so in-flight requests never carry a token that lapses mid-call.
+	tokenExpirySkew = time.Minute
+)
+
+// OAuthConfig holds the OAuth 2.0 client registration and any previously
+// persisted tokens.
+type OAuthConfig struct {
+	ClientID     string
+	ClientSecret string
+	// TokenURL defaults to Atlassian's token endpoint on Cloud and to
+	// {apiURL}/rest/oauth2/latest/token on Data Center.
+	TokenURL string
+	// AuthorizationCode and RedirectURI are exchanged once for the first
+	// token pair when no RefreshToken is available yet.
+	AuthorizationCode string
+	RedirectURI       string
+	RefreshToken      string
+	AccessToken       string
+	Expiry            time.Time
+}
+
+// OAuthToken is an access/refresh token pair issued by the token endpoint.
+// Refresh tokens rotate, so hosts should persist every token they receive
+// through JiraProvider.OnTokenRefresh.
+type OAuthToken struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+func (t OAuthToken) valid(now time.Time) bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(tokenExpirySkew).Before(t.Expiry))
+}
+
+// authMode returns Config.Auth, falling back to the deployment's default.
+func (c Config) authMode() string {
+	if c.Auth != "" {
+		return c.Auth
+	}
+	if c.dataCenter() {
+		return AuthBearer
+	}
+	return AuthBasic
+}
+
+func (c Config) usesOAuth() bool {
+	mode := c.authMode()
+	return mode == AuthOAuth2 || mode == AuthClientCredentials
+}
+
+// baseURL is the root that REST paths are appended to. OAuth access tokens
+// and service-account bearer tokens on Cloud are only accepted through the
+// api.atlassian.com gateway.
+func (c Config) baseURL() string {
+	viaGateway := c.usesOAuth() || (c.authMode() == AuthBearer && c.CloudID != "")
+	if viaGateway && !c.dataCenter() {
+		gateway := c.GatewayURL
+		if gateway == "" {
+			gateway = defaultGatewayURL
+		}
+		return gateway + "/ex/jira/" + c.CloudID
+	}
+	return c.APIURL
+}
+
+func (c Config) validateAuth() error {
+	switch c.authMode() {
+	case AuthBasic:
+		if c.APIToken == "" {
+			return errors.New("jira apiToken is required")
+		}
+		if c.Email == "" {
+			return errors.New("jira email is required")
+		}
+	case AuthBearer:
+		if c.APIToken == "" {
+			return errors.New("jira apiToken is required")
+		}
+	case AuthOAuth2, AuthClientCredentials:
+		if c.OAuth.ClientID == "" || c.OAuth.ClientSecret == "" {
+			return errors.New("jira oauthClientId and oauthClientSecret are required")
+		}
+		if c.authMode() == AuthOAuth2 && c.OAuth.RefreshToken == "" && c.OAuth.AuthorizationCode == "" && c.OAuth.AccessToken == "" {
+			return errors.New("jira oauth2 requires oauthRefreshToken, oauthAuthorizationCode or oauthAccessToken")
+		}
+		if !c.dataCenter() && c.CloudID == "" {
+			return errors.New("jira cloudId is required for OAuth on Jira Cloud")
+		}
+	default:
+		return fmt.Errorf("jira auth must be one of %q, %q, %q or %q, got %q", AuthBasic, AuthBearer, AuthOAuth2, AuthClientCredentials, c.Auth)
+	}
+	return nil
+}
+
+// tokenSource hands out OAuth access tokens, refreshing them before expiry.
+// The mutex ensures concurrent requests trigger at most one refresh.
+type tokenSource struct {
+	mode   string
+	cfg    OAuthConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	token     OAuthToken
+	code      string
+	onRefresh func(OAuthToken)
+}
+
+func newTokenSource(cfg Config, client *http.Client) *tokenSource {
+	oauth := cfg.OAuth
+	if oauth.TokenURL == "" {
+		if cfg.dataCenter() {
+			oauth.TokenURL = cfg.APIURL + "/rest/oauth2/latest/token"
+		} else {
+			oauth.TokenURL = defaultTokenURL
+		}
+	}
+	return &tokenSource{
+		mode:   cfg.authMode(),
+		cfg:    oauth,
+		client: client,
+		token: OAuthToken{
+			AccessToken:  oauth.AccessToken,
+			RefreshToken: oauth.RefreshToken,
+			Expiry:       oauth.Expiry,
+		},
+		code: oauth.AuthorizationCode,
+	}
+}
+
+// accessToken returns a valid access token, refreshing it when needed.
+func (s *tokenSource) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	if s.token.valid(time.Now()) {
+		token := s.token.AccessToken
+		s.mu.Unlock()
+		return token, nil
+	}
+	err := s.refreshLocked(ctx)
+	token, onRefresh := s.token, s.onRefresh
+	s.mu.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+	// Notify outside the lock so the hook may call back into the provider.
+	if onRefresh != nil {
+		onRefresh(token)
+	}
+	return token.AccessToken, nil
+}
+
+// invalidate discards the access token if it is still the one that Jira
+// rejected, forcing the next request to refresh.
+func (s *tokenSource) invalidate(rejected string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token.AccessToken == rejected {
+		s.token.AccessToken = ""
+	}
+}
+
+func (s *tokenSource) current() OAuthToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+func (s *tokenSource) setOnRefresh(fn func(OAuthToken)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRefresh = fn
+}
+
+func (s *tokenSource) refreshLocked(ctx context.Context) error {
+	// RFC 6749 form parameters: Data Center's token endpoint requires them
+	// and Atlassian Cloud accepts them too.
+	grant := url.Values{
+		"client_id":     {s.cfg.ClientID},
+		"client_secret": {s.cfg.ClientSecret},
+	}
+	switch {
+	case s.mode == AuthClientCredentials:
+		grant.Set("grant_type", "client_credentials")
+	case s.token.RefreshToken != "":
+		grant.Set("grant_type", "refresh_token")
+		grant.Set("refresh_token", s.token.RefreshToken)
+	case s.code != "":
+		grant.Set("grant_type", "authorization_code")
+		grant.Set("code", s.code)
+		grant.Set("redirect_uri", s.cfg.RedirectURI)
+	default:
+		return fmt.Errorf("%w: oauth access token expired and no refresh token is available", ErrUnauthorized)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(grant.Encode()))
+	if err != nil {
+		return fmt.Errorf("create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := newAPIError(resp)
+		if apiErr.StatusCode == http.StatusBadRequest {
+			// invalid_grant and friends: the credentials themselves are bad.
+			apiErr.StatusCode = http.StatusUnauthorized
+		}
+		return fmt.Errorf("refresh oauth token: %w", apiErr)
+	}
+
+	var result struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return fmt.Errorf("decode token response: %w", err)
+	}
+	if result.AccessToken == "" {
+		return errors.New("token response did not include an access_token")
+	}
+
+	token := OAuthToken{AccessToken: result.AccessToken, RefreshToken: s.token.RefreshToken}
+	if result.RefreshToken != "" {
+		token.RefreshToken = result.RefreshToken
+	}
+	if result.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+	s.token = token
+	// An authorization code is single-use.
+	s.code = ""
+	return nil
+}
+
+// authorize attaches credentials for the configured auth mode.
+func (p *JiraProvider) authorize(ctx context.Context, req *http.Request) error {
+	switch p.cfg.authMode() {
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+p.cfg.APIToken)
+	case AuthOAuth2, AuthClientCredentials:
+		if p.tokens == nil {
+			return errors.New("jira oauth token source is not configured")
+		}
+		token, err := p.tokens.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	default:
+		req.SetBasicAuth(p.cfg.Email, p.cfg.APIToken)
+	}
+	return nil
+}
+
+// OnTokenRefresh registers fn to be called with every token pair obtained
+// from the OAuth token endpoint so the host can persist rotated refresh
+// tokens. It is a no-op unless OAuth authentication is configured.
+func (p *JiraProvider) OnTokenRefresh(fn func(OAuthToken)) {
+	if p.tokens != nil {
+		p.tokens.setOnRefresh(fn)
+	}
+}
+
+// Token returns the current OAuth token pair, if OAuth is configured.
+func (p *JiraProvider) Token() (OAuthToken, bool) {
+	if p.tokens == nil {
+		return OAuthToken{}, false
+	}
+	return p.tokens.current(), true
+}
+
+// bearerToken extracts the token from a request's Authorization header.
+func bearerToken(req *http.Request) string {
+	return strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+}
@@ -0,0 +1,252 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeOAuth stands in for both auth.atlassian.com and the api.atlassian.com
+// gateway. Each grant issues access-N / refresh-N tokens.
+type fakeOAuth struct {
+	issued    int32
+	grants    []map[string]string
+	mu        sync.Mutex
+	expiresIn int
+}
+
+func (f *fakeOAuth) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		// Data Center's token endpoint only accepts RFC 6749 form bodies.
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+			t.Errorf("token request Content-Type = %q, want application/x-www-form-urlencoded", ct)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("token request is not a form: %v", err)
+		}
+		grant := map[string]string{}
+		for k := range r.PostForm {
+			grant[k] = r.PostForm.Get(k)
+		}
+		if grant["client_id"] != "client" || grant["client_secret"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		f.mu.Lock()
+		f.grants = append(f.grants, grant)
+		f.mu.Unlock()
+		if grant["grant_type"] == "refresh_token" && grant["refresh_token"] == "revoked" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		n := atomic.AddInt32(&f.issued, 1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access-%d", n),
+			"refresh_token": fmt.Sprintf("refresh-%d", n),
+			"expires_in":    f.expiresIn,
+		})
+	})
+	mux.HandleFunc("/ex/jira/cloud-123/rest/api/3/issue/PROJ-1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer stale" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"id": "10001", "key": "PROJ-1",
+			"fields": map[string]any{"summary": "t", "status": map[string]any{"name": "Open"}},
+		})
+	})
+	return mux
+}
+
+func newOAuthProvider(t *testing.T, serverURL string, extra map[string]any) *JiraProvider {
+	t.Helper()
+	cfg := map[string]any{
+		"auth":              "oauth2",
+		"cloudId":           "cloud-123",
+		"gatewayURL":        serverURL,
+		"apiURL":            "https://example.atlassian.net",
+		"projectKey":        "PROJ",
+		"oauthClientId":     "client",
+		"oauthClientSecret": "secret",
+		"oauthTokenURL":     serverURL + "/oauth/token",
+	}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	prov, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return prov.(*JiraProvider)
+}
+
+func TestOAuth(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("refresh token is exchanged and rotated tokens surfaced", func(t *testing.T) {
+		fake := &fakeOAuth{expiresIn: 3600}
+		server := httptest.NewServer(fake.handler(t))
+		defer server.Close()
+
+		p := newOAuthProvider(t, server.URL, map[string]any{"oauthRefreshToken": "refresh-0"})
+		var refreshed []OAuthToken
+		p.OnTokenRefresh(func(tok OAuthToken) { refreshed = append(refreshed, tok) })
+
+		ticket, err := p.Get(ctx, "PROJ-1")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if ticket.URL != "https://example.atlassian.net/browse/PROJ-1" {
+			t.Errorf("URL = %v, want browse link on apiURL", ticket.URL)
+		}
+		if len(refreshed) != 1 || refreshed[0].RefreshToken != "refresh-1" {
+			t.Fatalf("refreshed = %+v, want one rotated token", refreshed)
+		}
+		if fake.grants[0]["grant_type"] != "refresh_token" || fake.grants[0]["refresh_token"] != "refresh-0" {
+			t.Errorf("grant = %v", fake.grants[0])
+		}
+
+		// A still-valid token is reused.
+		if _, err := p.Get(ctx, "PROJ-1"); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if fake.issued != 1 {
+			t.Errorf("tokens issued = %v, want 1", fake.issued)
+		}
+	})
+
+	t.Run("concurrent requests refresh once", func(t *testing.T) {
+		fake := &fakeOAuth{expiresIn: 3600}
+		server := httptest.NewServer(fake.handler(t))
+		defer server.Close()
+
+		p := newOAuthProvider(t, server.URL, map[string]any{"oauthRefreshToken": "refresh-0"})
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := p.Get(ctx, "PROJ-1"); err != nil {
+					t.Errorf("Get() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if fake.issued != 1 {
+			t.Errorf("tokens issued = %v, want 1", fake.issued)
+		}
+	})
+
+	t.Run("token near expiry is refreshed ahead of time", func(t *testing.T) {
+		fake := &fakeOAuth{expiresIn: 3600}
+		server := httptest.NewServer(fake.handler(t))
+		defer server.Close()
+
+		p := newOAuthProvider(t, server.URL, map[string]any{
+			"oauthAccessToken":  "almost-expired",
+			"oauthRefreshToken": "refresh-0",
+			"oauthTokenExpiry":  time.Now().Add(30 * time.Second).Format(time.RFC3339),
+		})
+		if _, err := p.Get(ctx, "PROJ-1"); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if tok, _ := p.Token(); tok.AccessToken != "access-1" {
+			t.Errorf("AccessToken = %v, want access-1", tok.AccessToken)
+		}
+	})
+
+	t.Run("401 forces a single refresh", func(t *testing.T) {
+		fake := &fakeOAuth{expiresIn: 3600}
+		server := httptest.NewServer(fake.handler(t))
+		defer server.Close()
+
+		p := newOAuthProvider(t, server.URL, map[string]any{
+			"oauthAccessToken":  "stale",
+			"oauthRefreshToken": "refresh-0",
+		})
+		if _, err := p.Get(ctx, "PROJ-1"); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if fake.issued != 1 {
+			t.Errorf("tokens issued = %v, want 1", fake.issued)
+		}
+	})
+
+	t.Run("authorization code is exchanged once", func(t *testing.T) {
+		fake := &fakeOAuth{expiresIn: 3600}
+		server := httptest.NewServer(fake.handler(t))
+		defer server.Close()
+
+		p := newOAuthProvider(t, server.URL, map[string]any{
+			"oauthAuthorizationCode": "code-xyz",
+			"oauthRedirectURI":       "https://opsorch.example.com/callback",
+		})
+		if _, err := p.Get(ctx, "PROJ-1"); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		grant := fake.grants[0]
+		if grant["grant_type"] != "authorization_code" || grant["code"] != "code-xyz" || grant["redirect_uri"] != "https://opsorch.example.com/callback" {
+			t.Errorf("grant = %v", grant)
+		}
+	})
+
+	t.Run("client credentials for service accounts", func(t *testing.T) {
+		fake := &fakeOAuth{expiresIn: 3600}
+		server := httptest.NewServer(fake.handler(t))
+		defer server.Close()
+
+		p := newOAuthProvider(t, server.URL, map[string]any{"auth": "clientCredentials"})
+		if _, err := p.Get(ctx, "PROJ-1"); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if fake.grants[0]["grant_type"] != "client_credentials" {
+			t.Errorf("grant = %v", fake.grants[0])
+		}
+	})
+
+	t.Run("rejected refresh token is unauthorized", func(t *testing.T) {
+		fake := &fakeOAuth{expiresIn: 3600}
+		server := httptest.NewServer(fake.handler(t))
+		defer server.Close()
+
+		p := newOAuthProvider(t, server.URL, map[string]any{"oauthRefreshToken": "revoked"})
+		_, err := p.Get(ctx, "PROJ-1")
+		if !errors.Is(err, ErrForbidden) {
+			t.Errorf("Get() error = %v, want ErrForbidden", err)
+		}
+	})
+}
+
+func TestValidateAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "basic", cfg: Config{APIToken: "t", Email: "e"}},
+		{name: "basic without email", cfg: Config{APIToken: "t"}, wantErr: true},
+		{name: "bearer", cfg: Config{Auth: AuthBearer, APIToken: "t"}},
+		{name: "oauth2 without cloud id", cfg: Config{Auth: AuthOAuth2, OAuth: OAuthConfig{ClientID: "c", ClientSecret: "s", RefreshToken: "r"}}, wantErr: true},
+		{name: "oauth2 on data center", cfg: Config{Auth: AuthOAuth2, Deployment: DeploymentDataCenter, OAuth: OAuthConfig{ClientID: "c", ClientSecret: "s", RefreshToken: "r"}}},
+		{name: "oauth2 without grant", cfg: Config{Auth: AuthOAuth2, CloudID: "x", OAuth: OAuthConfig{ClientID: "c", ClientSecret: "s"}}, wantErr: true},
+		{name: "unknown", cfg: Config{Auth: "kerberos"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validateAuth(); (err != nil) != tt.wantErr {
+				t.Errorf("validateAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package ticket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Circuit breaker defaults applied by parseConfig.
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+	defaultRequestTimeout   = 30 * time.Second
+)
+
+// probeTimeout bounds the serverInfo request that decides whether an open
+// breaker may close again.
+const probeTimeout = 5 * time.Second
+
+// Breaker states reported by JiraProvider.Breaker.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerStatus is a snapshot of the provider's circuit breaker.
+type BreakerStatus struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	// OpenedAt and RetryAt are set while the breaker is not closed; RetryAt
+	// is when the next probe is allowed.
+	OpenedAt  *time.Time `json:"openedAt,omitempty"`
+	RetryAt   *time.Time `json:"retryAt,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// UnavailableError is returned without contacting Jira while the breaker is
+// open. It unwraps to ErrUnavailable.
+type UnavailableError struct {
+	// RetryAt is when the breaker next probes Jira.
+	RetryAt time.Time
+	// LastError describes the failure that kept the breaker open.
+	LastError string
+}
+
+func (e *UnavailableError) Error() string {
+	msg := "jira is unavailable"
+	if e.LastError != "" {
+		msg += ": " + e.LastError
+	}
+	if !e.RetryAt.IsZero() {
+		msg += fmt.Sprintf(" (next probe at %s)", e.RetryAt.UTC().Format(time.RFC3339))
+	}
+	return msg
+}
+
+// Unwrap exposes the sentinel for errors.Is.
+func (e *UnavailableError) Unwrap() error { return ErrUnavailable }
+
+// circuitBreaker stops calls to Jira after threshold consecutive failures.
+// Once cooldown has passed a single caller probes serverInfo; the breaker
+// closes if Jira answers and stays open for another cooldown otherwise.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	retryAt  time.Time
+	lastErr  string
+
+	// onClose runs when a probe closes the breaker; it must not block.
+	onClose func()
+}
+
+// newCircuitBreaker returns nil, which never trips, when threshold is zero.
+func newCircuitBreaker(cfg Config) *circuitBreaker {
+	if cfg.BreakerThreshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown, state: BreakerClosed}
+}
+
+// allow reports whether a request may be sent. probe is true when the
+// caller has been chosen to test an open breaker and must report back with
+// probeDone.
+func (b *circuitBreaker) allow(now time.Time) (probe bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerClosed:
+		return false, nil
+	case BreakerOpen:
+		if !now.Before(b.retryAt) {
+			b.state = BreakerHalfOpen
+			return true, nil
+		}
+	}
+	return false, &UnavailableError{RetryAt: b.retryAt, LastError: b.lastErr}
+}
+
+// probeDone closes the breaker after a successful probe, or reopens it for
+// another cooldown and returns the error for the probing caller.
+func (b *circuitBreaker) probeDone(ok bool, detail string, now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ok {
+		b.reset()
+		if b.onClose != nil {
+			b.onClose()
+		}
+		return nil
+	}
+	b.lastErr = detail
+	b.trip(now)
+	return &UnavailableError{RetryAt: b.retryAt, LastError: b.lastErr}
+}
+
+// probeAbandoned returns a half-open breaker to open without extending
+// retryAt, so the next caller probes straight away.
+func (b *circuitBreaker) probeAbandoned() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+}
+
+// record counts the outcome of a request. detail describes a failure.
+func (b *circuitBreaker) record(failed bool, detail string, now time.Time) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		if b.state == BreakerClosed {
+			b.failures = 0
+		}
+		return
+	}
+	b.failures++
+	b.lastErr = detail
+	if b.state == BreakerClosed && b.failures >= b.threshold {
+		b.trip(now)
+	}
+}
+
+func (b *circuitBreaker) trip(now time.Time) {
+	if b.state == BreakerClosed {
+		b.openedAt = now
+	}
+	b.state = BreakerOpen
+	b.retryAt = now.Add(b.cooldown)
+}
+
+func (b *circuitBreaker) reset() {
+	b.state = BreakerClosed
+	b.failures = 0
+	b.openedAt = time.Time{}
+	b.retryAt = time.Time{}
+	b.lastErr = ""
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	if b == nil {
+		return BreakerStatus{State: BreakerClosed}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BreakerStatus{State: b.state, ConsecutiveFailures: b.failures, LastError: b.lastErr}
+	if b.state != BreakerClosed {
+		openedAt, retryAt := b.openedAt, b.retryAt
+		st.OpenedAt, st.RetryAt = &openedAt, &retryAt
+	}
+	return st
+}
+
+// Breaker reports the state of the provider's circuit breaker. It never
+// calls Jira.
+func (p *JiraProvider) Breaker() BreakerStatus {
+	return p.breaker.status()
+}
+
+// checkBreaker fails fast while Jira is considered down, probing it once the
+// cooldown has passed.
+func (p *JiraProvider) checkBreaker(ctx context.Context) error {
+	probe, err := p.breaker.allow(time.Now())
+	if err != nil || !probe {
+		return err
+	}
+
+	// The caller giving up says nothing about Jira's health: a caller that
+	// is already done leaves the probe to the next one, and the probe itself
+	// runs detached from the caller's cancellation and deadline.
+	if err := ctx.Err(); err != nil {
+		p.breaker.probeAbandoned()
+		return err
+	}
+	detail, ok := p.probe(context.WithoutCancel(ctx))
+	return p.breaker.probeDone(ok, detail, time.Now())
+}
+
+// probe asks Jira for serverInfo, which is cheap and needs no permissions.
+// It is bounded by probeTimeout alone.
+// Any answer below 500 shows Jira is up, even if it rejects our credentials.
+func (p *JiraProvider) probe(ctx context.Context) (detail string, ok bool) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	req, err := p.newRequest(ctx, apiRequest{method: http.MethodGet, path: p.apiPath("/serverInfo")}, nil)
+	if err != nil {
+		return err.Error(), false
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err.Error(), false
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Sprintf("serverInfo returned %d", resp.StatusCode), false
+	}
+	return "", true
+}
+
+// outage reports whether a response status means Jira itself is failing.
+func outage(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var down atomic.Bool
+	var calls, probes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rest/api/3/serverInfo" {
+			atomic.AddInt32(&probes, 1)
+		} else {
+			atomic.AddInt32(&calls, 1)
+		}
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := Config{APIURL: server.URL, BreakerThreshold: 2, BreakerCooldown: 20 * time.Millisecond}
+	p := &JiraProvider{cfg: cfg, client: &http.Client{}, breaker: newCircuitBreaker(cfg)}
+	get := func() error {
+		resp, err := p.do(context.Background(), apiRequest{method: http.MethodGet, path: "/rest/api/3/issue/PROJ-1"})
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	down.Store(true)
+	for i := 0; i < 2; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("get() error = %v, want the 503 response", err)
+		}
+	}
+	if st := p.Breaker(); st.State != BreakerOpen || st.RetryAt == nil {
+		t.Fatalf("Breaker() = %+v, want open", st)
+	}
+
+	err := get()
+	var unavailable *UnavailableError
+	if !errors.Is(err, ErrUnavailable) || !errors.As(err, &unavailable) {
+		t.Fatalf("get() error = %v, want UnavailableError", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %v, want 2: an open breaker must not reach Jira", calls)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if err := get(); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("get() error = %v after a failed probe, want ErrUnavailable", err)
+	}
+	if probes != 1 || p.Breaker().State != BreakerOpen {
+		t.Errorf("probes = %v, state = %v, want one failed probe", probes, p.Breaker().State)
+	}
+
+	down.Store(false)
+	time.Sleep(25 * time.Millisecond)
+	if err := get(); err != nil {
+		t.Fatalf("get() error = %v after recovery", err)
+	}
+	if st := p.Breaker(); st.State != BreakerClosed || st.ConsecutiveFailures != 0 || probes != 2 {
+		t.Errorf("Breaker() = %+v after %v probes, want closed after 2", st, probes)
+	}
+}
+
+func TestCircuitBreakerProbeIgnoresCallerCancellation(t *testing.T) {
+	var probes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rest/api/3/serverInfo" {
+			atomic.AddInt32(&probes, 1)
+			time.Sleep(20 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := Config{APIURL: server.URL, BreakerThreshold: 1, BreakerCooldown: time.Millisecond}
+	p := &JiraProvider{cfg: cfg, client: &http.Client{}, breaker: newCircuitBreaker(cfg)}
+	p.breaker.record(true, "connection refused", time.Now())
+	time.Sleep(5 * time.Millisecond)
+	retryAt := *p.Breaker().RetryAt
+
+	// A caller that has already given up does not probe or push back the
+	// next probe.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.checkBreaker(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("checkBreaker() error = %v, want context.Canceled", err)
+	}
+	if st := p.Breaker(); st.State != BreakerOpen || !st.RetryAt.Equal(retryAt) || probes != 0 {
+		t.Fatalf("Breaker() = %+v after %d probes, want open with retryAt unchanged", st, probes)
+	}
+
+	// A deadline shorter than the probe does not fail it.
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := p.checkBreaker(ctx); err != nil {
+		t.Fatalf("checkBreaker() error = %v, want the probe to succeed", err)
+	}
+	if st := p.Breaker(); st.State != BreakerClosed || probes != 1 {
+		t.Errorf("Breaker() = %+v after %d probes, want closed", st, probes)
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	b := newCircuitBreaker(Config{BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusTooManyRequests} {
+		b.record(outage(status), http.StatusText(status), time.Now())
+	}
+	if st := b.status(); st.State != BreakerClosed {
+		t.Errorf("state = %v, want closed", st.State)
+	}
+	if newCircuitBreaker(Config{}) != nil {
+		t.Error("newCircuitBreaker() with zero threshold should disable the breaker")
+	}
+}
+
+func TestCircuitBreakerRunsOnClose(t *testing.T) {
+	b := newCircuitBreaker(Config{BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	closed := 0
+	b.onClose = func() { closed++ }
+
+	b.record(true, "down", time.Now())
+	if probe, _ := b.allow(time.Now().Add(time.Minute)); !probe {
+		t.Fatal("allow() after the cooldown did not pick a probe")
+	}
+	b.probeDone(true, "", time.Now())
+	if closed != 1 {
+		t.Errorf("onClose ran %d times, want once when the probe closed the breaker", closed)
+	}
+}
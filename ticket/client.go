@@ -0,0 +1,171 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// apiRequest describes a single call against the Jira REST API.
+type apiRequest struct {
+	method string
+	// path is relative to the API base URL, e.g. "/rest/api/3/issue".
+	path string
+	// body is JSON-encoded when non-nil.
+	body any
+	// rawBody is sent verbatim with contentType instead of a JSON body, e.g.
+	// for multipart uploads.
+	rawBody     []byte
+	contentType string
+	// header holds extra headers, applied after the defaults.
+	header http.Header
+	// idempotent marks a POST as safe to repeat after an ambiguous failure
+	// (for example a read-only search). GET, PUT and DELETE always are.
+	idempotent bool
+}
+
+// retrySafe reports whether the request may be replayed when it is unknown
+// whether Jira processed the previous attempt.
+func (r apiRequest) retrySafe() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.idempotent
+}
+
+// read reports whether the request draws from the read budget. The only
+// idempotent POSTs are searches, which are reads in all but method.
+func (r apiRequest) read() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		return r.idempotent
+	}
+	return false
+}
+
+// do sends the request, retrying throttled and transient failures according
+// to the configured retry budget. The caller owns the returned response body.
+func (p *JiraProvider) do(ctx context.Context, r apiRequest) (*http.Response, error) {
+	body := r.rawBody
+	if r.body != nil {
+		b, err := json.Marshal(r.body)
+		if err != nil {
+			return nil, fmt.Errorf("marshal request body: %w", err)
+		}
+		body = b
+	}
+
+	reauthorized := false
+	for attempt := 0; ; attempt++ {
+		if err := p.checkBreaker(ctx); err != nil {
+			return nil, err
+		}
+		req, err := p.newRequest(ctx, r, body)
+		if err != nil {
+			return nil, err
+		}
+		release, err := p.limiter.acquire(ctx, r.read(), p.retryMaxDelay())
+		if err != nil {
+			return nil, err
+		}
+		resp, err := p.client.Do(req)
+		if err != nil {
+			release()
+			if ctx.Err() == nil {
+				// The caller giving up says nothing about Jira's health.
+				p.breaker.record(true, err.Error(), time.Now())
+			}
+			if ctx.Err() != nil || !r.retrySafe() || attempt >= p.cfg.MaxRetries {
+				return nil, fmt.Errorf("execute request: %w", err)
+			}
+			if err := sleepContext(ctx, p.backoff(attempt)); err != nil {
+				return nil, fmt.Errorf("execute request: %w", err)
+			}
+			continue
+		}
+		p.limiter.observe(r.read(), resp, time.Now())
+		p.breaker.record(outage(resp.StatusCode), resp.Status, time.Now())
+		resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+
+		if resp.StatusCode == http.StatusUnauthorized && p.tokens != nil && !reauthorized {
+			// The access token may have been revoked or rotated elsewhere;
+			// refresh once before giving up. Jira rejected the request, so
+			// replaying it is safe for any method.
+			reauthorized = true
+			p.tokens.invalidate(bearerToken(req))
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			attempt--
+			continue
+		}
+
+		if attempt >= p.cfg.MaxRetries || !shouldRetry(resp.StatusCode, r.retrySafe()) {
+			return resp, nil
+		}
+
+		wait := p.backoff(attempt)
+		if hinted, ok := retryAfter(resp.Header, time.Now()); ok {
+			if hinted > p.retryMaxDelay() {
+				// Jira wants us to back off longer than we are willing to
+				// wait; surface the throttled response to the caller.
+				return resp, nil
+			}
+			wait = hinted
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, fmt.Errorf("execute request: %w", err)
+		}
+	}
+}
+
+// releasingBody frees the request's in-flight slot once the caller is done
+// with the response, so a streamed download counts until it is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+func (p *JiraProvider) newRequest(ctx context.Context, r apiRequest, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, p.cfg.baseURL()+r.path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	if err := p.authorize(ctx, req); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		contentType := r.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	return req, nil
+}
@@ -0,0 +1,161 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// commentPageSize bounds how many comments a single list request asks for.
+const commentPageSize = 100
+
+// Comment is a normalised Jira issue comment. Body is Markdown rendered from
+// ADF on Cloud and wiki markup on Data Center; BodyText is always plain text.
+type Comment struct {
+	ID         string    `json:"id"`
+	Author     string    `json:"author,omitempty"`
+	AuthorName string    `json:"authorName,omitempty"`
+	Body       string    `json:"body"`
+	BodyText   string    `json:"bodyText,omitempty"`
+	BodyFormat string    `json:"bodyFormat,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	// UpdatedBy is set when someone other than the author last edited it.
+	UpdatedBy string `json:"updatedBy,omitempty"`
+}
+
+// jiraComment represents a comment from the Jira API.
+type jiraComment struct {
+	ID           string          `json:"id"`
+	Author       *jiraUser       `json:"author"`
+	UpdateAuthor *jiraUser       `json:"updateAuthor"`
+	Body         jiraDescription `json:"body"`
+	Created      string          `json:"created"`
+	Updated      string          `json:"updated"`
+}
+
+// ListComments returns every comment on an issue, oldest first.
+func (p *JiraProvider) ListComments(ctx context.Context, issueID string) ([]Comment, error) {
+	var comments []Comment
+	for startAt := 0; ; {
+		q := url.Values{}
+		q.Set("startAt", strconv.Itoa(startAt))
+		q.Set("maxResults", strconv.Itoa(commentPageSize))
+		q.Set("orderBy", "created")
+
+		resp, err := p.do(ctx, apiRequest{method: http.MethodGet, path: p.apiPath("/issue/" + issueID + "/comment?" + q.Encode())})
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Comments []jiraComment `json:"comments"`
+			StartAt  int           `json:"startAt"`
+			Total    int           `json:"total"`
+		}
+		if err := decodeResponse(resp, http.StatusOK, &page); err != nil {
+			return nil, err
+		}
+
+		for _, c := range page.Comments {
+			comments = append(comments, convertJiraComment(c))
+		}
+		startAt = page.StartAt + len(page.Comments)
+		if len(page.Comments) == 0 || startAt >= page.Total {
+			return comments, nil
+		}
+	}
+}
+
+// AddComment posts a new comment. On Cloud the body is Markdown (or a raw ADF
+// document) and is converted like a description; on Data Center it is wiki
+// markup.
+func (p *JiraProvider) AddComment(ctx context.Context, issueID, body string) (Comment, error) {
+	resp, err := p.do(ctx, apiRequest{
+		method: http.MethodPost,
+		path:   p.apiPath("/issue/" + issueID + "/comment"),
+		body:   map[string]any{"body": p.descriptionValue(ctx, body)},
+	})
+	if err != nil {
+		return Comment{}, err
+	}
+
+	var c jiraComment
+	if err := decodeResponse(resp, http.StatusCreated, &c); err != nil {
+		return Comment{}, err
+	}
+	return convertJiraComment(c), nil
+}
+
+// UpdateComment replaces the body of an existing comment.
+func (p *JiraProvider) UpdateComment(ctx context.Context, issueID, commentID, body string) (Comment, error) {
+	resp, err := p.do(ctx, apiRequest{
+		method: http.MethodPut,
+		path:   p.apiPath("/issue/" + issueID + "/comment/" + commentID),
+		body:   map[string]any{"body": p.descriptionValue(ctx, body)},
+	})
+	if err != nil {
+		return Comment{}, err
+	}
+
+	var c jiraComment
+	if err := decodeResponse(resp, http.StatusOK, &c); err != nil {
+		return Comment{}, err
+	}
+	return convertJiraComment(c), nil
+}
+
+// DeleteComment removes a comment from an issue.
+func (p *JiraProvider) DeleteComment(ctx context.Context, issueID, commentID string) error {
+	resp, err := p.do(ctx, apiRequest{method: http.MethodDelete, path: p.apiPath("/issue/" + issueID + "/comment/" + commentID)})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return newAPIError(resp)
+	}
+	return nil
+}
+
+// decodeResponse checks the status code and decodes the JSON body into v,
+// closing the body either way.
+func decodeResponse(resp *http.Response, want int, v any) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		return newAPIError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func convertJiraComment(c jiraComment) Comment {
+	comment := Comment{
+		ID:         c.ID,
+		Body:       c.Body.markdown(),
+		BodyText:   c.Body.text(),
+		BodyFormat: c.Body.format(),
+	}
+	if c.Author != nil {
+		comment.Author = c.Author.ID()
+		comment.AuthorName = c.Author.DisplayName
+	}
+	if c.UpdateAuthor != nil && (c.Author == nil || c.UpdateAuthor.ID() != c.Author.ID()) {
+		comment.UpdatedBy = c.UpdateAuthor.ID()
+	}
+	if createdAt, ok := parseJiraTime(c.Created); ok {
+		comment.CreatedAt = createdAt
+	}
+	if updatedAt, ok := parseJiraTime(c.Updated); ok {
+		comment.UpdatedAt = updatedAt
+	}
+	return comment
+}
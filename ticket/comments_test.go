@@ -0,0 +1,124 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestComments(t *testing.T) {
+	var posted, put map[string]any
+	var deleted bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1/comment" && r.Method == "GET":
+			// Two pages of one comment each.
+			startAt, _ := strconv.Atoi(r.URL.Query().Get("startAt"))
+			comments := []map[string]any{
+				{
+					"id":      "100",
+					"author":  map[string]any{"accountId": "acc-1", "displayName": "Alice"},
+					"body":    map[string]any{"type": "doc", "version": 1, "content": []any{map[string]any{"type": "paragraph", "content": []any{map[string]any{"type": "text", "text": "Paged", "marks": []any{map[string]any{"type": "strong"}}}}}}},
+					"created": "2024-01-01T10:00:00Z",
+					"updated": "2024-01-01T10:00:00Z",
+				},
+				{
+					"id":           "101",
+					"author":       map[string]any{"accountId": "acc-1", "displayName": "Alice"},
+					"updateAuthor": map[string]any{"accountId": "acc-2", "displayName": "Bob"},
+					"body":         map[string]any{"type": "doc", "version": 1, "content": []any{map[string]any{"type": "paragraph", "content": []any{map[string]any{"type": "text", "text": "Mitigated"}}}}},
+					"created":      "2024-01-01T11:00:00Z",
+					"updated":      "2024-01-01T12:00:00Z",
+				},
+			}
+			json.NewEncoder(w).Encode(map[string]any{"comments": comments[startAt : startAt+1], "startAt": startAt, "total": 2})
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1/comment" && r.Method == "POST":
+			json.NewDecoder(r.Body).Decode(&posted)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{"id": "102", "body": posted["body"], "author": map[string]any{"accountId": "acc-1"}})
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1/comment/102" && r.Method == "PUT":
+			json.NewDecoder(r.Body).Decode(&put)
+			json.NewEncoder(w).Encode(map[string]any{"id": "102", "body": put["body"]})
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1/comment/102" && r.Method == "DELETE":
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"errorMessages": []string{"Can not find a comment for the id: 999."}})
+		}
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{
+		cfg:    Config{Source: "jira", APIURL: server.URL, ProjectKey: "PROJ"},
+		client: &http.Client{},
+	}
+	ctx := context.Background()
+
+	t.Run("list follows pages", func(t *testing.T) {
+		comments, err := p.ListComments(ctx, "PROJ-1")
+		if err != nil {
+			t.Fatalf("ListComments() error = %v", err)
+		}
+		if len(comments) != 2 {
+			t.Fatalf("len(comments) = %d, want 2", len(comments))
+		}
+		first, second := comments[0], comments[1]
+		if first.ID != "100" || first.Author != "acc-1" || first.AuthorName != "Alice" || first.Body != "**Paged**" || first.BodyText != "Paged" || first.BodyFormat != "markdown" {
+			t.Errorf("first comment = %+v", first)
+		}
+		if !first.CreatedAt.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) {
+			t.Errorf("CreatedAt = %v", first.CreatedAt)
+		}
+		if first.UpdatedBy != "" {
+			t.Errorf("UpdatedBy = %q, want empty for the author's own edit", first.UpdatedBy)
+		}
+		if second.UpdatedBy != "acc-2" || !second.UpdatedAt.Equal(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("second comment = %+v", second)
+		}
+	})
+
+	t.Run("add converts markdown", func(t *testing.T) {
+		c, err := p.AddComment(ctx, "PROJ-1", "Rolled back **v42**")
+		if err != nil {
+			t.Fatalf("AddComment() error = %v", err)
+		}
+		if body, ok := posted["body"].(map[string]any); !ok || body["type"] != "doc" {
+			t.Errorf("posted body = %v, want ADF doc", posted["body"])
+		}
+		if c.ID != "102" || c.Body != "Rolled back **v42**" {
+			t.Errorf("comment = %+v", c)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		c, err := p.UpdateComment(ctx, "PROJ-1", "102", "Rolled back v41")
+		if err != nil {
+			t.Fatalf("UpdateComment() error = %v", err)
+		}
+		if c.Body != "Rolled back v41" {
+			t.Errorf("Body = %q", c.Body)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := p.DeleteComment(ctx, "PROJ-1", "102"); err != nil {
+			t.Fatalf("DeleteComment() error = %v", err)
+		}
+		if !deleted {
+			t.Error("comment was not deleted")
+		}
+	})
+
+	t.Run("missing comment", func(t *testing.T) {
+		err := p.DeleteComment(ctx, "PROJ-1", "999")
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("DeleteComment() error = %v, want ErrNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package ticket
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// Supported values for Config.DedupMode.
+const (
+	// DedupOff creates every ticket as asked.
+	DedupOff = "off"
+	// DedupReuse returns a matching open issue instead of creating one.
+	DedupReuse = "reuse"
+	// DedupComment also records the recurrence as a comment on it.
+	DedupComment = "comment"
+)
+
+// Deduplication defaults applied by parseConfig.
+const defaultDedupWindow = 24 * time.Hour
+
+var defaultDedupFields = []string{"service", "alertName", "labels"}
+
+// fingerprintLabelPrefix marks the label carrying a ticket's fingerprint.
+// A label rather than an entity property keeps the lookup a plain JQL
+// query that needs no index configuration.
+const fingerprintLabelPrefix = "opsorch-fp-"
+
+// fingerprint hashes the configured dedup fields of in. It is empty when
+// none of them has a value, since such tickets have nothing in common.
+func (p *JiraProvider) fingerprint(in schema.CreateTicketInput) string {
+	parts := make([]string, 0, len(p.cfg.DedupFields))
+	found := false
+	for _, field := range p.cfg.DedupFields {
+		v := dedupValue(in, field)
+		if v != "" {
+			found = true
+		}
+		parts = append(parts, field+"="+v)
+	}
+	if !found {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// dedupValue reads field from the input's metadata, then its fields, and
+// normalises it so case and list order do not matter. "title" is the
+// ticket title.
+func dedupValue(in schema.CreateTicketInput, field string) string {
+	if field == "title" {
+		return strings.ToLower(strings.TrimSpace(in.Title))
+	}
+	v, ok := in.Metadata[field]
+	if !ok {
+		v = in.Fields[field]
+	}
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.ToLower(strings.TrimSpace(v))
+	case []string, []any:
+		items := stringList(v)
+		for i := range items {
+			items[i] = strings.ToLower(items[i])
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// fingerprintJQL finds open issues in the project carrying fp within the
+// dedup window, newest first.
+func (p *JiraProvider) fingerprintJQL(fp string) string {
+	clauses := []string{
+		fmt.Sprintf("project = %s", p.cfg.ProjectKey),
+		fmt.Sprintf("labels = \"%s\"", fingerprintLabelPrefix+fp),
+	}
+	if p.cfg.DedupWindow > 0 {
+		clauses = append(clauses, fmt.Sprintf("created >= \"-%dm\"", int(p.cfg.DedupWindow.Minutes())))
+	}
+	if len(p.cfg.DedupOpenStatuses) > 0 {
+		statuses := make([]string, len(p.cfg.DedupOpenStatuses))
+		for i, s := range p.cfg.DedupOpenStatuses {
+			statuses[i] = fmt.Sprintf("\"%s\"", escapeJQL(s))
+		}
+		clauses = append(clauses, fmt.Sprintf("status IN (%s)", strings.Join(statuses, ",")))
+	} else {
+		clauses = append(clauses, "statusCategory != Done")
+	}
+	return strings.Join(clauses, " AND ") + " ORDER BY created DESC"
+}
+
+// findDuplicate returns the open issue sharing fp, if any. A create with
+// an external key tags the issue with it first, so a retry finds it by key
+// instead of deduplicating (and commenting) again. In comment mode the
+// recurrence is noted on it; failing to comment does not fail the call
+// because the caller's ticket exists either way.
+func (p *JiraProvider) findDuplicate(ctx context.Context, fp, key string, in schema.CreateTicketInput) (schema.Ticket, bool, error) {
+	page, err := p.searchPage(ctx, p.fingerprintJQL(fp), "", 1)
+	if err != nil {
+		return schema.Ticket{}, false, fmt.Errorf("look up fingerprint %s: %w", fp, err)
+	}
+	if len(page.Issues) == 0 {
+		return schema.Ticket{}, false, nil
+	}
+	t := p.convert(page.Issues[0])
+	t.Metadata["fingerprint"] = fp
+	t.Metadata["deduplicated"] = true
+	if key != "" {
+		if err := p.tagExternalKey(ctx, t.Key, key); err != nil {
+			return schema.Ticket{}, false, err
+		}
+		t.Metadata["external_id"] = key
+	}
+
+	if p.cfg.DedupMode == DedupComment {
+		c, err := p.AddComment(ctx, t.Key, recurrenceComment(in, time.Now()))
+		if err != nil {
+			t.Metadata["recurrence_comment_error"] = err.Error()
+		} else {
+			t.Metadata["recurrence_comment_id"] = c.ID
+		}
+	}
+	return t, true, nil
+}
+
+func recurrenceComment(in schema.CreateTicketInput, at time.Time) string {
+	body := fmt.Sprintf("Recurred at %s: %s", at.UTC().Format(time.RFC3339), in.Title)
+	if in.Description != "" {
+		body += "\n\n" + in.Description
+	}
+	return body
+}
@@ -0,0 +1,253 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+func TestCreateDeduplicates(t *testing.T) {
+	var mu sync.Mutex
+	var creates, comments int
+	var label string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/rest/api/3/search/jql":
+			var payload struct {
+				JQL string `json:"jql"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			if label != "" && strings.Contains(payload.JQL, `labels = "`+label+`"`) {
+				w.Write([]byte(`{"issues":[{"id":"10001","key":"PROJ-1","fields":{"summary":"CPU high","status":{"name":"Investigating"}}}],"isLast":true}`))
+				return
+			}
+			w.Write([]byte(`{"issues":[],"isLast":true}`))
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1/comment":
+			comments++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"20001","body":"recurred"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/3/issue":
+			var payload struct {
+				Fields struct {
+					Labels []string `json:"labels"`
+				} `json:"fields"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			for _, l := range payload.Fields.Labels {
+				if strings.HasPrefix(l, fingerprintLabelPrefix) {
+					label = l
+				}
+			}
+			creates++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"10001","key":"PROJ-1"}`))
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`{"id":"10001","key":"PROJ-1","fields":{"summary":"CPU high","status":{"name":"To Do"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{
+		cfg: Config{
+			APIURL:      server.URL,
+			ProjectKey:  "PROJ",
+			Source:      "jira",
+			DedupMode:   DedupComment,
+			DedupFields: defaultDedupFields,
+		},
+		client: &http.Client{},
+	}
+	alert := func(source string, labels ...any) schema.CreateTicketInput {
+		return schema.CreateTicketInput{
+			Title:    "CPU high from " + source,
+			Metadata: map[string]any{"service": "checkout", "alertName": "HighCPU"},
+			Fields:   map[string]any{"labels": labels},
+		}
+	}
+
+	first, err := p.Create(context.Background(), alert("prometheus", "prod", "eu"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	second, err := p.Create(context.Background(), alert("datadog", "EU", "prod"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if creates != 1 || comments != 1 {
+		t.Errorf("creates = %d, comments = %d, want 1 and 1", creates, comments)
+	}
+	if second.Key != first.Key || second.Metadata["deduplicated"] != true || second.Metadata["recurrence_comment_id"] != "20001" {
+		t.Errorf("second Create() = %s %v, want the first issue reused", second.Key, second.Metadata)
+	}
+	if label != fingerprintLabelPrefix+first.Metadata["fingerprint"].(string) {
+		t.Errorf("label = %q, fingerprint = %v", label, first.Metadata["fingerprint"])
+	}
+
+	if _, err := p.Create(context.Background(), alert("prometheus", "staging")); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if creates != 2 {
+		t.Errorf("creates = %d, want a new issue for different labels", creates)
+	}
+}
+
+func TestDeduplicatedCreateIsTaggedWithExternalID(t *testing.T) {
+	var mu sync.Mutex
+	var creates, comments int
+	var labels, ids []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		issue := `{"id":"10001","key":"PROJ-1","fields":{"summary":"CPU high","status":{"name":"To Do"}}}`
+		switch {
+		case r.URL.Path == "/rest/api/3/search/jql":
+			var payload struct {
+				JQL string `json:"jql"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			for _, id := range ids {
+				if strings.Contains(payload.JQL, `externalIds = "`+id+`"`) {
+					w.Write([]byte(`{"issues":[` + issue + `],"isLast":true}`))
+					return
+				}
+			}
+			for _, l := range labels {
+				if strings.Contains(payload.JQL, `labels = "`+l+`"`) {
+					w.Write([]byte(`{"issues":[` + issue + `],"isLast":true}`))
+					return
+				}
+			}
+			w.Write([]byte(`{"issues":[],"isLast":true}`))
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1/properties/opsorch":
+			if r.Method == http.MethodGet {
+				json.NewEncoder(w).Encode(map[string]any{"key": "opsorch", "value": externalKeys{IDs: ids}})
+				return
+			}
+			var value externalKeys
+			json.NewDecoder(r.Body).Decode(&value)
+			ids = value.IDs
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPut && r.URL.Path == "/rest/api/3/issue/PROJ-1":
+			var payload struct {
+				Update struct {
+					Labels []struct {
+						Add string `json:"add"`
+					} `json:"labels"`
+				} `json:"update"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			for _, l := range payload.Update.Labels {
+				labels = append(labels, l.Add)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1/comment":
+			comments++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"20001","body":"recurred"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/3/issue":
+			var payload struct {
+				Fields struct {
+					Labels []string `json:"labels"`
+				} `json:"fields"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			// Only the fingerprint label is kept, so retries must find the
+			// issue through the property list or a label added by tagging.
+			for _, l := range payload.Fields.Labels {
+				if strings.HasPrefix(l, fingerprintLabelPrefix) {
+					labels = append(labels, l)
+				}
+			}
+			ids = []string{"INC-1"}
+			creates++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"10001","key":"PROJ-1"}`))
+		case r.Method == http.MethodGet:
+			w.Write([]byte(issue))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{
+		cfg: Config{
+			APIURL:              server.URL,
+			ProjectKey:          "PROJ",
+			Source:              "jira",
+			IdempotencyProperty: "opsorch",
+			DedupMode:           DedupComment,
+			DedupFields:         defaultDedupFields,
+		},
+		client: &http.Client{},
+	}
+	alert := func(incident string) schema.CreateTicketInput {
+		return schema.CreateTicketInput{
+			Title:    "CPU high",
+			Metadata: map[string]any{"service": "checkout", "alertName": "HighCPU", "externalId": incident},
+		}
+	}
+
+	if _, err := p.Create(context.Background(), alert("INC-1")); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	// A second incident for the same alert reuses the issue and tags it, so
+	// retrying that create finds it by key without commenting again.
+	for i := 0; i < 2; i++ {
+		got, err := p.Create(context.Background(), alert("INC-2"))
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if got.Key != "PROJ-1" || got.Metadata["external_id"] != "INC-2" {
+			t.Errorf("Create() = %s %v, want PROJ-1 tagged INC-2", got.Key, got.Metadata)
+		}
+	}
+	if creates != 1 || comments != 1 || strings.Join(ids, ",") != "INC-1,INC-2" {
+		t.Errorf("creates = %d, comments = %d, ids = %v, want 1, 1 and both incidents", creates, comments, ids)
+	}
+	if !slices.Contains(labels, externalKeyLabel("INC-2")) {
+		t.Errorf("labels = %v, want the INC-2 external id label", labels)
+	}
+
+	// The first incident still finds its issue, even once it is closed and
+	// out of the dedup window.
+	got, err := p.Create(context.Background(), alert("INC-1"))
+	if err != nil || got.Metadata["existing"] != true || creates != 1 {
+		t.Errorf("retried first Create() = %v, %v, creates = %d, want the existing issue", got.Metadata, err, creates)
+	}
+}
+
+func TestFingerprintJQL(t *testing.T) {
+	p := &JiraProvider{cfg: Config{ProjectKey: "PROJ", DedupWindow: 2 * time.Hour}}
+	want := `project = PROJ AND labels = "opsorch-fp-abc" AND created >= "-120m" AND statusCategory != Done ORDER BY created DESC`
+	if got := p.fingerprintJQL("abc"); got != want {
+		t.Errorf("fingerprintJQL() = %q, want %q", got, want)
+	}
+
+	p.cfg.DedupWindow = 0
+	p.cfg.DedupOpenStatuses = []string{"Open", "Triage"}
+	want = `project = PROJ AND labels = "opsorch-fp-abc" AND status IN ("Open","Triage") ORDER BY created DESC`
+	if got := p.fingerprintJQL("abc"); got != want {
+		t.Errorf("fingerprintJQL() = %q, want %q", got, want)
+	}
+}
+
+func TestFingerprintNeedsValues(t *testing.T) {
+	p := &JiraProvider{cfg: Config{DedupFields: defaultDedupFields}}
+	if fp := p.fingerprint(schema.CreateTicketInput{Title: "anything"}); fp != "" {
+		t.Errorf("fingerprint() = %q, want empty without any dedup field", fp)
+	}
+}
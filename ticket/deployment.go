@@ -0,0 +1,115 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Supported values for Config.Deployment.
+const (
+	// DeploymentCloud targets Jira Cloud: REST API v3, email + API token
+	// basic auth, accountId user references and ADF descriptions.
+	DeploymentCloud = "cloud"
+	// DeploymentDataCenter targets Jira Server/Data Center: REST API v2,
+	// Personal Access Token bearer auth, username references and wiki-markup
+	// descriptions.
+	DeploymentDataCenter = "datacenter"
+)
+
+func (c Config) dataCenter() bool {
+	return c.Deployment == DeploymentDataCenter
+}
+
+// apiPath prefixes path with the REST API root for the configured deployment,
+// e.g. "/issue" becomes "/rest/api/3/issue" on Cloud.
+func (p *JiraProvider) apiPath(path string) string {
+	if p.cfg.dataCenter() {
+		return "/rest/api/2" + path
+	}
+	return "/rest/api/3" + path
+}
+
+// userRef builds the JSON reference Jira expects when assigning a user.
+// Cloud identifies users by accountId, Data Center by username.
+func (p *JiraProvider) userRef(id string) map[string]string {
+	if p.cfg.dataCenter() {
+		return map[string]string{"name": id}
+	}
+	return map[string]string{"accountId": id}
+}
+
+// descriptionValue encodes a description for the configured deployment. On
+// Cloud, Markdown is converted to ADF (or a raw ADF document is passed
+// through); on Data Center the wiki-markup string is sent as is.
+func (p *JiraProvider) descriptionValue(ctx context.Context, text string) any {
+	if p.cfg.dataCenter() {
+		return text
+	}
+	if raw, ok := rawADF(text); ok {
+		return raw
+	}
+	doc := markdownToADF(text)
+	content := p.resolveMentions(ctx, doc.Content, map[string]*jiraUser{})
+	if content == nil {
+		content = []adfNode{}
+	}
+	return map[string]any{
+		"type":    "doc",
+		"version": 1,
+		"content": content,
+	}
+}
+
+// searchPageOffset runs one page of POST /rest/api/2/search. Data Center has
+// no nextPageToken, so the cursor is the decimal startAt offset of the page.
+func (p *JiraProvider) searchPageOffset(ctx context.Context, jql, pageToken string, maxResults int) (searchResponse, error) {
+	startAt := 0
+	if pageToken != "" {
+		n, err := strconv.Atoi(pageToken)
+		if err != nil || n < 0 {
+			return searchResponse{}, fmt.Errorf("%w: invalid page token %q", ErrValidation, pageToken)
+		}
+		startAt = n
+	}
+
+	resp, err := p.do(ctx, apiRequest{
+		method: http.MethodPost,
+		path:   p.apiPath("/search"),
+		body: map[string]any{
+			"jql":        jql,
+			"startAt":    startAt,
+			"maxResults": maxResults,
+			"fields":     []string{"*all"},
+		},
+		idempotent: true,
+	})
+	if err != nil {
+		return searchResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return searchResponse{}, newAPIError(resp)
+	}
+
+	var result struct {
+		Issues  []jiraIssue `json:"issues"`
+		StartAt int         `json:"startAt"`
+		Total   int         `json:"total"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return searchResponse{}, fmt.Errorf("decode response: %w", err)
+	}
+
+	page := searchResponse{Issues: result.Issues}
+	next := result.StartAt + len(result.Issues)
+	if len(result.Issues) == 0 || next >= result.Total {
+		page.IsLast = true
+	} else {
+		page.NextPageToken = strconv.Itoa(next)
+	}
+	return page, nil
+}
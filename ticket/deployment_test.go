@@ -0,0 +1,116 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+func TestDataCenterDeployment(t *testing.T) {
+	var created, updated map[string]any
+	var searchOffsets []float64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-pat" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/rest/api/2/issue" && r.Method == "POST":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{"id": "10001", "key": "PROJ-1"})
+		case r.URL.Path == "/rest/api/2/issue/PROJ-1" && r.Method == "PUT":
+			json.NewDecoder(r.Body).Decode(&updated)
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/rest/api/2/issue/PROJ-1" && r.Method == "GET":
+			json.NewEncoder(w).Encode(map[string]any{
+				"id":  "10001",
+				"key": "PROJ-1",
+				"fields": map[string]any{
+					"summary":     "Disk full",
+					"description": "h1. Runbook\n* clear /tmp",
+					"status":      map[string]any{"name": "Open"},
+					"assignee":    map[string]any{"name": "alice", "key": "JIRAUSER1", "displayName": "Alice"},
+					"reporter":    map[string]any{"name": "bob", "displayName": "Bob"},
+				},
+			})
+		case r.URL.Path == "/rest/api/2/search" && r.Method == "POST":
+			var payload map[string]any
+			json.NewDecoder(r.Body).Decode(&payload)
+			startAt := payload["startAt"].(float64)
+			searchOffsets = append(searchOffsets, startAt)
+			issues := []map[string]any{
+				{"id": "1", "key": "PROJ-1", "fields": map[string]any{"summary": "a", "status": map[string]any{"name": "Open"}}},
+			}
+			json.NewEncoder(w).Encode(map[string]any{"issues": issues, "startAt": startAt, "total": 3})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	prov, err := New(map[string]any{
+		"apiToken":   "test-pat",
+		"apiURL":     server.URL,
+		"projectKey": "PROJ",
+		"deployment": "datacenter",
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	p := prov.(*JiraProvider)
+	ctx := context.Background()
+
+	t.Run("create sends wiki markup description", func(t *testing.T) {
+		ticket, err := p.Create(ctx, schema.CreateTicketInput{Title: "Disk full", Description: "h1. Runbook"})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		fields := created["fields"].(map[string]any)
+		if fields["description"] != "h1. Runbook" {
+			t.Errorf("description = %v, want wiki markup string", fields["description"])
+		}
+		if ticket.Description != "h1. Runbook\n* clear /tmp" {
+			t.Errorf("Description = %q", ticket.Description)
+		}
+		if len(ticket.Assignees) != 1 || ticket.Assignees[0] != "alice" {
+			t.Errorf("Assignees = %v, want [alice]", ticket.Assignees)
+		}
+		if ticket.Reporter != "bob" {
+			t.Errorf("Reporter = %v, want bob", ticket.Reporter)
+		}
+	})
+
+	t.Run("update assigns by username", func(t *testing.T) {
+		assignees := []string{"carol"}
+		if _, err := p.Update(ctx, "PROJ-1", schema.UpdateTicketInput{Assignees: &assignees}); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		assignee := updated["fields"].(map[string]any)["assignee"].(map[string]any)
+		if assignee["name"] != "carol" {
+			t.Errorf("assignee = %v, want name carol", assignee)
+		}
+	})
+
+	t.Run("query pages by startAt", func(t *testing.T) {
+		searchOffsets = nil
+		res, err := p.QueryPage(ctx, schema.TicketQuery{Limit: 2})
+		if err != nil {
+			t.Fatalf("QueryPage() error = %v", err)
+		}
+		if len(res.Tickets) != 2 {
+			t.Errorf("len(tickets) = %v, want 2", len(res.Tickets))
+		}
+		if len(searchOffsets) != 2 || searchOffsets[1] != 1 {
+			t.Errorf("startAt offsets = %v, want [0 1]", searchOffsets)
+		}
+		if res.NextPageToken != "2" {
+			t.Errorf("NextPageToken = %v, want 2", res.NextPageToken)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package ticket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+)
+
+// Sentinel errors classify Jira failures. Every error returned by the
+// provider for a non-success response matches one of these with errors.Is
+// when the status maps onto a known category.
+var (
+	ErrNotFound     error = &kindError{code: "not_found", msg: "ticket not found"}
+	ErrUnauthorized error = &kindError{code: "unauthorized", msg: "jira authentication failed"}
+	ErrForbidden    error = &kindError{code: "forbidden", msg: "jira permission denied"}
+	ErrRateLimited  error = &kindError{code: "rate_limited", msg: "jira rate limit exceeded"}
+	ErrConflict     error = &kindError{code: "conflict", msg: "jira conflict"}
+	ErrValidation   error = &kindError{code: "bad_request", msg: "jira rejected the request"}
+	// ErrUnavailable means the circuit breaker is open and Jira was not
+	// contacted.
+	ErrUnavailable error = &kindError{code: "unavailable", msg: "jira is unavailable"}
+)
+
+// kindError is the concrete type behind the sentinels. Its code follows
+// opsorch-core's orcherr conventions so Core can map it to an HTTP status.
+type kindError struct {
+	code string
+	msg  string
+}
+
+func (e *kindError) Error() string { return e.msg }
+
+// As lets errors.As convert a sentinel into an orcherr.OpsOrchError.
+func (e *kindError) As(target any) bool {
+	return setOpsOrchError(target, e.code, e.msg)
+}
+
+// APIError is returned for any non-success Jira response. It carries the
+// decoded {"errorMessages":[],"errors":{}} body and unwraps to the sentinel
+// matching its status code.
+type APIError struct {
+	StatusCode int
+	// Messages holds Jira's errorMessages entries.
+	Messages []string
+	// FieldErrors maps a field ID to Jira's validation message for it.
+	FieldErrors map[string]string
+	// RetryAfter is the server-requested wait on a 429, if any.
+	RetryAfter time.Duration
+	// Body is the raw response body when it was not a Jira error payload.
+	Body string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("jira api error: %d %s", e.StatusCode, e.detail())
+}
+
+// Unwrap exposes the sentinel for errors.Is.
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return ErrValidation
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// As converts the error into an orcherr.OpsOrchError carrying Jira's own
+// messages, so Core surfaces something more useful than the sentinel text.
+func (e *APIError) As(target any) bool {
+	kind, ok := e.Unwrap().(*kindError)
+	if !ok {
+		return false
+	}
+	msg := e.detail()
+	if msg == "" {
+		msg = kind.msg
+	}
+	return setOpsOrchError(target, kind.code, msg)
+}
+
+func (e *APIError) detail() string {
+	parts := append([]string(nil), e.Messages...)
+	fields := make([]string, 0, len(e.FieldErrors))
+	for field := range e.FieldErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		parts = append(parts, field+": "+e.FieldErrors[field])
+	}
+	if len(parts) == 0 {
+		return e.Body
+	}
+	return strings.Join(parts, "; ")
+}
+
+func setOpsOrchError(target any, code, msg string) bool {
+	switch t := target.(type) {
+	case *orcherr.OpsOrchError:
+		*t = orcherr.New(code, msg, nil)
+		return true
+	case **orcherr.OpsOrchError:
+		oe := orcherr.New(code, msg, nil)
+		*t = &oe
+		return true
+	}
+	return false
+}
+
+// newAPIError drains resp and decodes Jira's error payload.
+func newAPIError(resp *http.Response) *APIError {
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	var payload struct {
+		ErrorMessages []string          `json:"errorMessages"`
+		Errors        map[string]string `json:"errors"`
+	}
+	if err := json.Unmarshal(bodyBytes, &payload); err == nil && (len(payload.ErrorMessages) > 0 || len(payload.Errors) > 0) {
+		apiErr.Messages = payload.ErrorMessages
+		apiErr.FieldErrors = payload.Errors
+	} else {
+		apiErr.Body = strings.TrimSpace(string(bodyBytes))
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if wait, ok := retryAfter(resp.Header, time.Now()); ok {
+			apiErr.RetryAfter = wait
+		}
+	}
+	return apiErr
+}
@@ -0,0 +1,118 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opsorch/opsorch-core/orcherr"
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+func TestAPIErrorClassification(t *testing.T) {
+	tests := []struct {
+		status   int
+		sentinel error
+		code     string
+	}{
+		{status: http.StatusBadRequest, sentinel: ErrValidation, code: "bad_request"},
+		{status: http.StatusUnauthorized, sentinel: ErrUnauthorized, code: "unauthorized"},
+		{status: http.StatusForbidden, sentinel: ErrForbidden, code: "forbidden"},
+		{status: http.StatusNotFound, sentinel: ErrNotFound, code: "not_found"},
+		{status: http.StatusConflict, sentinel: ErrConflict, code: "conflict"},
+		{status: http.StatusTooManyRequests, sentinel: ErrRateLimited, code: "rate_limited"},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", &APIError{StatusCode: tt.status, Messages: []string{"boom"}})
+			if !errors.Is(err, tt.sentinel) {
+				t.Errorf("errors.Is(%v) = false, want true", tt.sentinel)
+			}
+			var oe orcherr.OpsOrchError
+			if !errors.As(err, &oe) {
+				t.Fatalf("errors.As(OpsOrchError) = false, want true")
+			}
+			if oe.Code != tt.code || oe.Message != "boom" {
+				t.Errorf("OpsOrchError = %+v, want code %s message boom", oe, tt.code)
+			}
+		})
+	}
+
+	t.Run("unclassified status", func(t *testing.T) {
+		err := &APIError{StatusCode: http.StatusInternalServerError, Body: "oops"}
+		var oe orcherr.OpsOrchError
+		if errors.As(err, &oe) {
+			t.Errorf("errors.As(OpsOrchError) = true, want false")
+		}
+		if err.Error() != "jira api error: 500 oops" {
+			t.Errorf("Error() = %v", err.Error())
+		}
+	})
+}
+
+func TestNewAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/3/issue":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errorMessages":["Issue could not be created"],"errors":{"summary":"You must specify a summary of the issue.","priority":"Priority name 'Sev0' is not valid"}}`))
+		case "/rest/api/3/issue/PROJ-1":
+			w.Header().Set("Retry-After", "42")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("not allowed"))
+		}
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{
+		cfg:    Config{Source: "jira", APIURL: server.URL, ProjectKey: "PROJ", DefaultIssueType: "Task"},
+		client: &http.Client{},
+	}
+	ctx := context.Background()
+
+	t.Run("validation errors are decoded per field", func(t *testing.T) {
+		_, err := p.Create(ctx, schema.CreateTicketInput{})
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("Create() error = %v, want *APIError", err)
+		}
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("errors.Is(ErrValidation) = false")
+		}
+		if apiErr.FieldErrors["summary"] != "You must specify a summary of the issue." {
+			t.Errorf("FieldErrors = %v", apiErr.FieldErrors)
+		}
+		want := "jira api error: 400 Issue could not be created; priority: Priority name 'Sev0' is not valid; summary: You must specify a summary of the issue."
+		if err.Error() != want {
+			t.Errorf("Error() = %v, want %v", err.Error(), want)
+		}
+	})
+
+	t.Run("rate limit carries retry-after", func(t *testing.T) {
+		_, err := p.Get(ctx, "PROJ-1")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("Get() error = %v, want rate limited *APIError", err)
+		}
+		if apiErr.RetryAfter != 42*time.Second {
+			t.Errorf("RetryAfter = %v, want 42s", apiErr.RetryAfter)
+		}
+	})
+
+	t.Run("non-json body is kept verbatim", func(t *testing.T) {
+		_, err := p.Query(ctx, schema.TicketQuery{})
+		if !errors.Is(err, ErrForbidden) {
+			t.Errorf("Query() error = %v, want ErrForbidden", err)
+		}
+		if err.Error() != "jira api error: 403 not allowed" {
+			t.Errorf("Error() = %v", err.Error())
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package ticket
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// defaultIdempotencyProperty is the issue entity property that records the
+// external key a ticket was created for.
+const defaultIdempotencyProperty = "opsorch"
+
+// externalKeyLabelPrefix marks the label carrying a hash of a ticket's
+// external key. JQL only matches entity properties an app has indexed, so
+// the label keeps the lookup working on a plain Jira site.
+const externalKeyLabelPrefix = "opsorch-ext-"
+
+// externalKeyLabel is the label recording key; labels may not contain
+// spaces, so the key is hashed.
+func externalKeyLabel(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return externalKeyLabelPrefix + hex.EncodeToString(sum[:8])
+}
+
+// externalKey returns the key that makes a create idempotent: the caller's
+// metadata["externalId"] (for example the OpsOrch incident ID), else
+// metadata["idempotencyKey"]. Empty means the create is not deduplicated.
+func externalKey(metadata map[string]any) string {
+	if v, ok := metadata["externalId"].(string); ok && strings.TrimSpace(v) != "" {
+		return strings.TrimSpace(v)
+	}
+	return idempotencyKey(metadata)
+}
+
+// keyLocks serialises creates that share an external key within this
+// process, so two concurrent retries cannot both miss the lookup.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (k *keyLocks) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*keyLock{}
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// externalKeyJQL finds issues in the project tagged with key, by entity
+// property where it is indexed and by label otherwise. A property holds a
+// list of keys, since deduplication can point several creates at one
+// issue; JQL matches a list that contains key. Issues tagged before the
+// list existed hold a single externalId.
+func (p *JiraProvider) externalKeyJQL(key string) string {
+	prop := p.cfg.IdempotencyProperty
+	return fmt.Sprintf("project = %s AND (issue.property[%s].externalIds = \"%s\" OR issue.property[%s].externalId = \"%s\" OR labels = \"%s\")",
+		p.cfg.ProjectKey, prop, escapeJQL(key), prop, escapeJQL(key), externalKeyLabel(key))
+}
+
+// findByExternalKey returns the issue previously created for key, if any.
+func (p *JiraProvider) findByExternalKey(ctx context.Context, key string) (schema.Ticket, bool, error) {
+	page, err := p.searchPage(ctx, p.externalKeyJQL(key)+" ORDER BY created ASC", "", 1)
+	if err != nil {
+		return schema.Ticket{}, false, fmt.Errorf("look up external id %q: %w", key, err)
+	}
+	if len(page.Issues) == 0 {
+		return schema.Ticket{}, false, nil
+	}
+	return p.convert(page.Issues[0]), true, nil
+}
+
+// externalKeyProperty is the property set on an issue at creation time.
+func (p *JiraProvider) externalKeyProperty(key string) map[string]any {
+	return map[string]any{
+		"key":   p.cfg.IdempotencyProperty,
+		"value": externalKeys{IDs: []string{key}},
+	}
+}
+
+// externalKeys is the value of the idempotency property.
+type externalKeys struct {
+	IDs []string `json:"externalIds"`
+	// Legacy is the single key of an issue tagged before IDs existed.
+	Legacy string `json:"externalId,omitempty"`
+}
+
+// tagExternalKey adds key to an existing issue, as creation does for a new
+// one. Keys already on the issue are kept, so every create pointed at it
+// still finds it.
+func (p *JiraProvider) tagExternalKey(ctx context.Context, issue, key string) error {
+	path := p.apiPath("/issue/" + issue + "/properties/" + url.PathEscape(p.cfg.IdempotencyProperty))
+	resp, err := p.do(ctx, apiRequest{method: http.MethodGet, path: path})
+	if err != nil {
+		return fmt.Errorf("tag %s with external id %q: %w", issue, key, err)
+	}
+	var prop struct {
+		Value externalKeys `json:"value"`
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+	} else if err := decodeResponse(resp, http.StatusOK, &prop); err != nil {
+		return fmt.Errorf("tag %s with external id %q: %w", issue, key, err)
+	}
+
+	keys := prop.Value
+	if keys.Legacy != "" && !slices.Contains(keys.IDs, keys.Legacy) {
+		keys.IDs = append(keys.IDs, keys.Legacy)
+	}
+	keys.Legacy = ""
+	if !slices.Contains(keys.IDs, key) {
+		keys.IDs = append(keys.IDs, key)
+	}
+	resp, err = p.do(ctx, apiRequest{method: http.MethodPut, path: path, body: keys})
+	if err != nil {
+		return fmt.Errorf("tag %s with external id %q: %w", issue, key, err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		defer resp.Body.Close()
+		return fmt.Errorf("tag %s with external id %q: %w", issue, key, newAPIError(resp))
+	}
+	resp.Body.Close()
+
+	// The label is what finds the issue where the property is not indexed.
+	resp, err = p.do(ctx, apiRequest{
+		method: http.MethodPut,
+		path:   p.apiPath("/issue/" + issue),
+		body:   map[string]any{"update": map[string]any{"labels": []any{map[string]string{"add": externalKeyLabel(key)}}}},
+	})
+	if err != nil {
+		return fmt.Errorf("label %s with external id %q: %w", issue, key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("label %s with external id %q: %w", issue, key, newAPIError(resp))
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+func TestCreateIdempotent(t *testing.T) {
+	var mu sync.Mutex
+	var creates int
+	var tagged string
+	var searched []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/rest/api/3/search/jql":
+			var payload struct {
+				JQL string `json:"jql"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			searched = append(searched, payload.JQL)
+			if tagged != "" && strings.Contains(payload.JQL, `"`+tagged+`"`) {
+				w.Write([]byte(`{"issues":[{"id":"10001","key":"PROJ-1","fields":{"summary":"Disk full","status":{"name":"To Do"}}}],"isLast":true}`))
+				return
+			}
+			w.Write([]byte(`{"issues":[],"isLast":true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/3/issue":
+			var payload struct {
+				Properties []struct {
+					Key   string       `json:"key"`
+					Value externalKeys `json:"value"`
+				} `json:"properties"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			if len(payload.Properties) == 1 && payload.Properties[0].Key == "opsorch" {
+				tagged = strings.Join(payload.Properties[0].Value.IDs, ",")
+			}
+			creates++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"10001","key":"PROJ-1"}`))
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`{"id":"10001","key":"PROJ-1","fields":{"summary":"Disk full","status":{"name":"To Do"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{
+		cfg:    Config{APIURL: server.URL, ProjectKey: "PROJ", Source: "jira", IdempotencyProperty: "opsorch"},
+		client: &http.Client{},
+	}
+	in := schema.CreateTicketInput{Title: "Disk full", Metadata: map[string]any{"externalId": "INC-42"}}
+
+	first, err := p.Create(context.Background(), in)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	second, err := p.Create(context.Background(), in)
+	if err != nil {
+		t.Fatalf("retried Create() error = %v", err)
+	}
+
+	if creates != 1 || tagged != "INC-42" {
+		t.Errorf("creates = %d, tagged = %q, want one issue tagged INC-42", creates, tagged)
+	}
+	if first.Key != "PROJ-1" || second.Key != "PROJ-1" || second.Metadata["existing"] != true {
+		t.Errorf("Create() = %s then %s (%v), want the same issue reused", first.Key, second.Key, second.Metadata)
+	}
+	if second.Metadata["external_id"] != "INC-42" {
+		t.Errorf("external_id = %v, want INC-42", second.Metadata["external_id"])
+	}
+	want := `project = PROJ AND (issue.property[opsorch].externalIds = "INC-42" OR issue.property[opsorch].externalId = "INC-42" OR labels = "` + externalKeyLabel("INC-42") + `") ORDER BY created ASC`
+	if len(searched) == 0 || searched[0] != want {
+		t.Errorf("JQL = %v, want %q", searched, want)
+	}
+
+	// Without a key nothing is looked up.
+	searched = nil
+	if _, err := p.Create(context.Background(), schema.CreateTicketInput{Title: "Other"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if len(searched) != 0 {
+		t.Errorf("searched %v for a create without a key", searched)
+	}
+}
+
+// TestCreateIdempotentWithoutIndexedProperty covers a site where no app
+// indexes the entity property: the issue carries it, but JQL on it never
+// matches, so the retry must be found by label.
+func TestCreateIdempotentWithoutIndexedProperty(t *testing.T) {
+	var mu sync.Mutex
+	var creates int
+	var labels []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/rest/api/3/search/jql":
+			var payload struct {
+				JQL string `json:"jql"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			for _, l := range labels {
+				if strings.Contains(payload.JQL, `labels = "`+l+`"`) {
+					w.Write([]byte(`{"issues":[{"id":"10001","key":"PROJ-1","fields":{"summary":"Disk full","status":{"name":"To Do"}}}],"isLast":true}`))
+					return
+				}
+			}
+			w.Write([]byte(`{"issues":[],"isLast":true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/3/issue":
+			var payload struct {
+				Fields struct {
+					Labels []string `json:"labels"`
+				} `json:"fields"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			labels = payload.Fields.Labels
+			creates++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"10001","key":"PROJ-1"}`))
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`{"id":"10001","key":"PROJ-1","fields":{"summary":"Disk full","status":{"name":"To Do"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{
+		cfg:    Config{APIURL: server.URL, ProjectKey: "PROJ", Source: "jira", IdempotencyProperty: "opsorch"},
+		client: &http.Client{},
+	}
+	in := schema.CreateTicketInput{Title: "Disk full", Fields: map[string]any{"labels": []string{"db"}}, Metadata: map[string]any{"externalId": "INC-42"}}
+
+	if _, err := p.Create(context.Background(), in); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	second, err := p.Create(context.Background(), in)
+	if err != nil {
+		t.Fatalf("retried Create() error = %v", err)
+	}
+	if creates != 1 || second.Metadata["existing"] != true {
+		t.Errorf("creates = %d, existing = %v, want the retry to find the issue by label", creates, second.Metadata["existing"])
+	}
+	if strings.Join(labels, ",") != "db,"+externalKeyLabel("INC-42") {
+		t.Errorf("labels = %v, want the caller's labels and the external id label", labels)
+	}
+}
+
+func TestExternalKey(t *testing.T) {
+	tests := []struct {
+		metadata map[string]any
+		want     string
+	}{
+		{metadata: map[string]any{"externalId": " INC-1 ", "idempotencyKey": "k"}, want: "INC-1"},
+		{metadata: map[string]any{"idempotencyKey": "k"}, want: "k"},
+		{metadata: nil, want: ""},
+	}
+	for _, tt := range tests {
+		if got := externalKey(tt.metadata); got != tt.want {
+			t.Errorf("externalKey(%v) = %q, want %q", tt.metadata, got, tt.want)
+		}
+	}
+}
@@ -1,13 +1,12 @@
 package ticket
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,12 +19,10 @@ const ProviderName = "jira"
 
 // AdapterVersion and RequiresCore express compatibility.
 const (
-	AdapterVersion = "0.1.0"
-	RequiresCore   = ">=0.1.0"
+	AdapterVersion = "0.2.0"
+	RequiresCore   = ">=0.4.0"
 )
 
-var errNotFound = errors.New("ticket not found")
-
 // Config captures decrypted configuration from OpsOrch Core.
 type Config struct {
 	Source           string
@@ -34,22 +31,125 @@ type Config struct {
 	Email            string
 	ProjectKey       string
 	DefaultIssueType string
+	// Deployment selects the Jira flavour: DeploymentCloud (default) or
+	// DeploymentDataCenter. On Data Center APIToken is a Personal Access Token.
+	Deployment string
+	// Auth selects how requests are authenticated (AuthBasic, AuthBearer,
+	// AuthOAuth2 or AuthClientCredentials). Empty picks the deployment default.
+	Auth string
+	// CloudID identifies the Cloud site behind the api.atlassian.com gateway,
+	// which OAuth requests are routed through instead of APIURL.
+	CloudID    string
+	GatewayURL string
+	OAuth      OAuthConfig
+
+	// MaxRetries bounds how many times a throttled or failed call is retried.
+	MaxRetries int
+	// RetryBaseDelay is the initial backoff, doubled on every attempt up to
+	// RetryMaxDelay. A Retry-After longer than RetryMaxDelay is not waited out.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// ReadRateLimit and WriteRateLimit are sustained requests per second for
+	// reads (fetches and searches) and writes, each allowed to burst to
+	// ReadBurst and WriteBurst. Zero disables pacing for that class.
+	ReadRateLimit  float64
+	ReadBurst      int
+	WriteRateLimit float64
+	WriteBurst     int
+	// MaxInFlightReads and MaxInFlightWrites cap concurrent requests per
+	// class. Zero removes the cap.
+	MaxInFlightReads  int
+	MaxInFlightWrites int
+
+	// RequestTimeout bounds a single HTTP attempt against Jira.
+	RequestTimeout time.Duration
+	// BreakerThreshold is the number of consecutive outages (network errors
+	// or 5xx responses) that open the circuit breaker; zero disables it.
+	// While open, calls fail at once with ErrUnavailable until a serverInfo
+	// probe succeeds, attempted every BreakerCooldown.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// IdempotencyProperty is the issue entity property holding the external
+	// key a ticket was created for, so a retried create finds it again.
+	IdempotencyProperty string
+
+	// StatusMapping and PriorityMapping translate OpsOrch values (lifecycle
+	// states, severities) into Jira status or transition names and priority
+	// names. Tables are keyed by project key, with "*" applying to every
+	// project; reads apply them in reverse.
+	StatusMapping   map[string]map[string]string
+	PriorityMapping map[string]map[string]string
+
+	// DedupMode makes Create reuse an open issue whose fingerprint matches
+	// (DedupReuse), optionally commenting on the recurrence (DedupComment).
+	// The fingerprint hashes the DedupFields values from the input's
+	// metadata or fields. Only issues created within DedupWindow and in one
+	// of DedupOpenStatuses (any status outside the Done category when empty)
+	// are reused.
+	DedupMode         string
+	DedupFields       []string
+	DedupWindow       time.Duration
+	DedupOpenStatuses []string
+
+	// OutboxDir enables the durable outbox: Create and Update calls that
+	// cannot reach Jira are recorded there and replayed in order later. It
+	// names a directory under the root given by OutboxRootEnv.
+	OutboxDir string
+
+	// MaxAttachmentSize caps attachment uploads and downloads in bytes. Zero
+	// disables the limit.
+	MaxAttachmentSize int64
+	// AttachmentMIMEDetection selects how an upload's content type is chosen
+	// when the caller does not give one (MIMEDetectAuto by default).
+	AttachmentMIMEDetection string
+	// AllowedAttachmentTypes restricts uploads to these MIME types; "image/*"
+	// matches a whole family. Empty allows any type.
+	AllowedAttachmentTypes []string
 }
 
-// JiraProvider integrates with Jira REST API v3.
+// JiraProvider integrates with Jira Cloud (REST API v3) and Jira Server/Data
+// Center (REST API v2).
 type JiraProvider struct {
 	cfg    Config
 	client *http.Client
+	// tokens is set when OAuth authentication is configured.
+	tokens *tokenSource
+	// limiter paces every call; nil sends requests unthrottled.
+	limiter *rateLimiter
+	// breaker fails calls fast while Jira is down; nil never trips.
+	breaker *circuitBreaker
+	// outbox queues writes while Jira is unreachable; nil when disabled.
+	outbox *outbox
+	// replayWake and stopReplayer drive the background replayer, which
+	// runs only with an outbox.
+	replayWake   chan struct{}
+	stopReplayer context.CancelFunc
+	// createLocks serialises creates that share an external key.
+	createLocks keyLocks
+	// workflows caches workflow graphs for multi-step transitions.
+	workflows workflowCache
 }
 
 // New constructs the provider from decrypted config.
 func New(cfg map[string]any) (coreticket.Provider, error) {
 	parsed := parseConfig(cfg)
-	if parsed.APIToken == "" {
-		return nil, errors.New("jira apiToken is required")
+	if parsed.Deployment != DeploymentCloud && parsed.Deployment != DeploymentDataCenter {
+		return nil, fmt.Errorf("jira deployment must be %q or %q, got %q", DeploymentCloud, DeploymentDataCenter, parsed.Deployment)
+	}
+	if err := parsed.validateAuth(); err != nil {
+		return nil, err
 	}
-	if parsed.Email == "" {
-		return nil, errors.New("jira email is required")
+	switch parsed.AttachmentMIMEDetection {
+	case MIMEDetectAuto, MIMEDetectExtension, MIMEDetectContent, MIMEDetectOff:
+	default:
+		return nil, fmt.Errorf("jira attachmentMimeDetection must be one of %q, %q, %q or %q, got %q", MIMEDetectAuto, MIMEDetectExtension, MIMEDetectContent, MIMEDetectOff, parsed.AttachmentMIMEDetection)
+	}
+	switch parsed.DedupMode {
+	case DedupOff, DedupReuse, DedupComment:
+	default:
+		return nil, fmt.Errorf("jira dedupMode must be %q, %q or %q, got %q", DedupOff, DedupReuse, DedupComment, parsed.DedupMode)
 	}
 	if parsed.ProjectKey == "" {
 		return nil, errors.New("jira projectKey is required")
@@ -57,10 +157,31 @@ func New(cfg map[string]any) (coreticket.Provider, error) {
 	if parsed.APIURL == "" {
 		return nil, errors.New("jira apiURL is required")
 	}
-	return &JiraProvider{
-		cfg:    parsed,
-		client: &http.Client{Timeout: 30 * time.Second},
-	}, nil
+	p := &JiraProvider{
+		cfg:     parsed,
+		client:  &http.Client{Timeout: parsed.RequestTimeout},
+		limiter: newRateLimiter(parsed),
+		breaker: newCircuitBreaker(parsed),
+	}
+	if parsed.usesOAuth() {
+		p.tokens = newTokenSource(parsed, p.client)
+	}
+	if parsed.OutboxDir != "" {
+		dir, err := outboxPath(parsed.OutboxDir)
+		if err != nil {
+			return nil, err
+		}
+		ob, err := openOutbox(dir)
+		if err != nil {
+			return nil, err
+		}
+		p.outbox = ob
+		p.startReplayer()
+		if p.breaker != nil {
+			p.breaker.onClose = p.wakeReplayer
+		}
+	}
+	return p, nil
 }
 
 func parseConfig(cfg map[string]any) Config {
@@ -68,6 +189,28 @@ func parseConfig(cfg map[string]any) Config {
 		Source:           "jira",
 		APIURL:           "https://your-domain.atlassian.net",
 		DefaultIssueType: "Task",
+		Deployment:       DeploymentCloud,
+		MaxRetries:       defaultMaxRetries,
+		RetryBaseDelay:   defaultRetryBaseDelay,
+		RetryMaxDelay:    defaultRetryMaxDelay,
+
+		ReadRateLimit:     defaultReadRateLimit,
+		ReadBurst:         defaultReadBurst,
+		WriteRateLimit:    defaultWriteRateLimit,
+		WriteBurst:        defaultWriteBurst,
+		MaxInFlightReads:  defaultMaxInFlightReads,
+		MaxInFlightWrites: defaultMaxInFlightWrites,
+
+		RequestTimeout:   defaultRequestTimeout,
+		BreakerThreshold: defaultBreakerThreshold,
+		BreakerCooldown:  defaultBreakerCooldown,
+
+		IdempotencyProperty:     defaultIdempotencyProperty,
+		DedupMode:               DedupOff,
+		DedupFields:             defaultDedupFields,
+		DedupWindow:             defaultDedupWindow,
+		MaxAttachmentSize:       defaultMaxAttachmentSize,
+		AttachmentMIMEDetection: MIMEDetectAuto,
 	}
 	if v, ok := cfg["source"].(string); ok && v != "" {
 		out.Source = v
@@ -87,15 +230,196 @@ func parseConfig(cfg map[string]any) Config {
 	if v, ok := cfg["defaultIssueType"].(string); ok && v != "" {
 		out.DefaultIssueType = v
 	}
+	if v, ok := cfg["deployment"].(string); ok && v != "" {
+		out.Deployment = strings.ToLower(strings.TrimSpace(v))
+		if out.Deployment == "server" || out.Deployment == "data-center" {
+			out.Deployment = DeploymentDataCenter
+		}
+	}
+	if v, ok := cfg["auth"].(string); ok && v != "" {
+		out.Auth = strings.TrimSpace(v)
+	}
+	if v, ok := cfg["cloudId"].(string); ok {
+		out.CloudID = strings.TrimSpace(v)
+	}
+	if v, ok := cfg["gatewayURL"].(string); ok && v != "" {
+		out.GatewayURL = strings.TrimRight(strings.TrimSpace(v), "/")
+	}
+	if v, ok := cfg["oauthClientId"].(string); ok {
+		out.OAuth.ClientID = strings.TrimSpace(v)
+	}
+	if v, ok := cfg["oauthClientSecret"].(string); ok {
+		out.OAuth.ClientSecret = strings.TrimSpace(v)
+	}
+	if v, ok := cfg["oauthTokenURL"].(string); ok {
+		out.OAuth.TokenURL = strings.TrimSpace(v)
+	}
+	if v, ok := cfg["oauthAuthorizationCode"].(string); ok {
+		out.OAuth.AuthorizationCode = strings.TrimSpace(v)
+	}
+	if v, ok := cfg["oauthRedirectURI"].(string); ok {
+		out.OAuth.RedirectURI = strings.TrimSpace(v)
+	}
+	if v, ok := cfg["oauthRefreshToken"].(string); ok {
+		out.OAuth.RefreshToken = strings.TrimSpace(v)
+	}
+	if v, ok := cfg["oauthAccessToken"].(string); ok {
+		out.OAuth.AccessToken = strings.TrimSpace(v)
+	}
+	if v, ok := cfg["oauthTokenExpiry"].(string); ok && v != "" {
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(v)); err == nil {
+			out.OAuth.Expiry = t
+		}
+	}
+	if v, ok := intValue(cfg["maxRetries"]); ok && v >= 0 {
+		out.MaxRetries = v
+	}
+	if v, ok := durationValue(cfg["retryBaseDelay"]); ok && v > 0 {
+		out.RetryBaseDelay = v
+	}
+	if v, ok := durationValue(cfg["retryMaxDelay"]); ok && v > 0 {
+		out.RetryMaxDelay = v
+	}
+	if v, ok := floatValue(cfg["readRateLimit"]); ok && v >= 0 {
+		out.ReadRateLimit = v
+	}
+	if v, ok := intValue(cfg["readBurst"]); ok && v > 0 {
+		out.ReadBurst = v
+	}
+	if v, ok := floatValue(cfg["writeRateLimit"]); ok && v >= 0 {
+		out.WriteRateLimit = v
+	}
+	if v, ok := intValue(cfg["writeBurst"]); ok && v > 0 {
+		out.WriteBurst = v
+	}
+	if v, ok := intValue(cfg["maxInFlightReads"]); ok && v >= 0 {
+		out.MaxInFlightReads = v
+	}
+	if v, ok := intValue(cfg["maxInFlightWrites"]); ok && v >= 0 {
+		out.MaxInFlightWrites = v
+	}
+	if v, ok := durationValue(cfg["requestTimeout"]); ok && v > 0 {
+		out.RequestTimeout = v
+	}
+	if v, ok := intValue(cfg["breakerThreshold"]); ok && v >= 0 {
+		out.BreakerThreshold = v
+	}
+	if v, ok := durationValue(cfg["breakerCooldown"]); ok && v > 0 {
+		out.BreakerCooldown = v
+	}
+	if v, ok := intValue(cfg["maxAttachmentSize"]); ok && v >= 0 {
+		out.MaxAttachmentSize = int64(v)
+	}
+	if v, ok := cfg["attachmentMimeDetection"].(string); ok && v != "" {
+		out.AttachmentMIMEDetection = strings.ToLower(strings.TrimSpace(v))
+	}
+	out.AllowedAttachmentTypes = stringList(cfg["allowedAttachmentTypes"])
+	if v, ok := cfg["idempotencyProperty"].(string); ok && strings.TrimSpace(v) != "" {
+		out.IdempotencyProperty = strings.TrimSpace(v)
+	}
+	out.StatusMapping = mappingTables(cfg["statusMapping"])
+	out.PriorityMapping = mappingTables(cfg["priorityMapping"])
+	if v, ok := cfg["dedupMode"].(string); ok && v != "" {
+		out.DedupMode = strings.ToLower(strings.TrimSpace(v))
+	}
+	if fields := stringList(cfg["dedupFields"]); len(fields) > 0 {
+		out.DedupFields = fields
+	}
+	if v, ok := durationValue(cfg["dedupWindow"]); ok && v >= 0 {
+		out.DedupWindow = v
+	}
+	out.DedupOpenStatuses = stringList(cfg["dedupOpenStatuses"])
+	if v, ok := cfg["outboxDir"].(string); ok {
+		out.OutboxDir = strings.TrimSpace(v)
+	}
 	return out
 }
 
+// intValue accepts the numeric shapes a decoded JSON or YAML config can hold.
+func intValue(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(n))
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// floatValue is intValue for settings that may be fractional.
+func floatValue(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	i, ok := intValue(v)
+	return float64(i), ok
+}
+
+// stringList accepts a list or a comma-separated string.
+func stringList(v any) []string {
+	var items []string
+	switch v := v.(type) {
+	case []string:
+		items = v
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+	case string:
+		items = strings.Split(v, ",")
+	}
+	var out []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// durationValue accepts Go duration strings ("500ms", "2s") or a number of
+// milliseconds.
+func durationValue(v any) (time.Duration, bool) {
+	if s, ok := v.(string); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		return d, err == nil
+	}
+	if ms, ok := intValue(v); ok {
+		return time.Duration(ms) * time.Millisecond, true
+	}
+	return 0, false
+}
+
 func init() {
 	_ = coreticket.RegisterProvider(ProviderName, New)
 }
 
-// Create creates a new Jira issue.
+// Create creates a new Jira issue. When in.Metadata carries an externalId
+// or idempotencyKey, an issue already created for that key is returned
+// instead of opening a duplicate. With an outbox configured, a create that
+// cannot reach Jira is queued and a provisional ticket is returned.
 func (p *JiraProvider) Create(ctx context.Context, in schema.CreateTicketInput) (schema.Ticket, error) {
+	return p.write(ctx, outboxEntry{Key: idempotencyKey(in.Metadata), Op: outboxCreate, Create: &in})
+}
+
+func (p *JiraProvider) create(ctx context.Context, in schema.CreateTicketInput) (schema.Ticket, error) {
 	payload := map[string]any{
 		"fields": map[string]any{
 			"project": map[string]string{
@@ -108,33 +432,53 @@ func (p *JiraProvider) Create(ctx context.Context, in schema.CreateTicketInput)
 		},
 	}
 
+	// Look the external key up first so a retried create returns the
+	// issue it already opened.
+	key := externalKey(in.Metadata)
+	if key != "" {
+		unlock := p.createLocks.lock(key)
+		defer unlock()
+		existing, ok, err := p.findByExternalKey(ctx, key)
+		if err != nil {
+			return schema.Ticket{}, err
+		}
+		if ok {
+			existing.Metadata["external_id"] = key
+			existing.Metadata["existing"] = true
+			return existing, nil
+		}
+		payload["properties"] = []map[string]any{p.externalKeyProperty(key)}
+	}
+
+	// Reuse an open issue raised for the same alert.
+	var fp string
+	if p.cfg.DedupMode != DedupOff {
+		if fp = p.fingerprint(in); fp != "" {
+			unlock := p.createLocks.lock(fingerprintLabelPrefix + fp)
+			defer unlock()
+			dup, ok, err := p.findDuplicate(ctx, fp, key, in)
+			if err != nil {
+				return schema.Ticket{}, err
+			}
+			if ok {
+				return dup, nil
+			}
+		}
+	}
+
 	if in.Description != "" {
-		payload["fields"].(map[string]any)["description"] = map[string]any{
-			"type":    "doc",
-			"version": 1,
-			"content": []map[string]any{
-				{
-					"type": "paragraph",
-					"content": []map[string]any{
-						{
-							"type": "text",
-							"text": in.Description,
-						},
-					},
-				},
-			},
+		payload["fields"].(map[string]any)["description"] = p.descriptionValue(ctx, in.Description)
+	}
+
+	// Handle priority, translating OpsOrch severities through the mapping
+	if priority := p.priorityName(in.Fields, in.Metadata); priority != "" {
+		payload["fields"].(map[string]any)["priority"] = map[string]string{
+			"name": priority,
 		}
 	}
 
 	// Add custom fields if provided
 	if in.Fields != nil {
-		// Handle priority
-		if priority, ok := in.Fields["priority"].(string); ok && priority != "" {
-			payload["fields"].(map[string]any)["priority"] = map[string]string{
-				"name": priority,
-			}
-		}
-
 		// Handle labels
 		if labels, ok := in.Fields["labels"].([]string); ok && len(labels) > 0 {
 			payload["fields"].(map[string]any)["labels"] = labels
@@ -173,29 +517,26 @@ func (p *JiraProvider) Create(ctx context.Context, in schema.CreateTicketInput)
 		}
 	}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return schema.Ticket{}, fmt.Errorf("marshal create payload: %w", err)
+	if fp != "" {
+		fields := payload["fields"].(map[string]any)
+		labels, _ := fields["labels"].([]string)
+		fields["labels"] = append(labels, fingerprintLabelPrefix+fp)
 	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", p.cfg.APIURL+"/rest/api/3/issue", bytes.NewReader(body))
-	if err != nil {
-		return schema.Ticket{}, fmt.Errorf("create request: %w", err)
+	if key != "" {
+		fields := payload["fields"].(map[string]any)
+		labels, _ := fields["labels"].([]string)
+		fields["labels"] = append(labels, externalKeyLabel(key))
 	}
 
-	req.SetBasicAuth(p.cfg.Email, p.cfg.APIToken)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := p.client.Do(req)
+	// Issue creation is not idempotent, so only throttled attempts are retried.
+	resp, err := p.do(ctx, apiRequest{method: http.MethodPost, path: p.apiPath("/issue"), body: payload})
 	if err != nil {
-		return schema.Ticket{}, fmt.Errorf("execute request: %w", err)
+		return schema.Ticket{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return schema.Ticket{}, fmt.Errorf("jira api error: %d %s", resp.StatusCode, string(bodyBytes))
+		return schema.Ticket{}, newAPIError(resp)
 	}
 
 	var result struct {
@@ -207,33 +548,31 @@ func (p *JiraProvider) Create(ctx context.Context, in schema.CreateTicketInput)
 		return schema.Ticket{}, fmt.Errorf("decode response: %w", err)
 	}
 
-	// Fetch the created issue to get full details
-	return p.Get(ctx, result.Key)
+	// Fetch the created issue to get full details. If that fails the issue
+	// still exists, so its key is returned with the error.
+	t, err := p.Get(ctx, result.Key)
+	if err != nil {
+		return schema.Ticket{ID: result.ID, Key: result.Key}, fmt.Errorf("fetch created issue %s: %w", result.Key, err)
+	}
+	if key != "" {
+		t.Metadata["external_id"] = key
+	}
+	if fp != "" {
+		t.Metadata["fingerprint"] = fp
+	}
+	return t, nil
 }
 
 // Get retrieves a single Jira issue by ID or key.
 func (p *JiraProvider) Get(ctx context.Context, id string) (schema.Ticket, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", p.cfg.APIURL+"/rest/api/3/issue/"+id, nil)
-	if err != nil {
-		return schema.Ticket{}, fmt.Errorf("create request: %w", err)
-	}
-
-	req.SetBasicAuth(p.cfg.Email, p.cfg.APIToken)
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := p.client.Do(req)
+	resp, err := p.do(ctx, apiRequest{method: http.MethodGet, path: p.apiPath("/issue/" + id)})
 	if err != nil {
-		return schema.Ticket{}, fmt.Errorf("execute request: %w", err)
+		return schema.Ticket{}, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return schema.Ticket{}, errNotFound
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return schema.Ticket{}, fmt.Errorf("jira api error: %d %s", resp.StatusCode, string(bodyBytes))
+		return schema.Ticket{}, newAPIError(resp)
 	}
 
 	var issue jiraIssue
@@ -241,63 +580,126 @@ func (p *JiraProvider) Get(ctx context.Context, id string) (schema.Ticket, error
 		return schema.Ticket{}, fmt.Errorf("decode response: %w", err)
 	}
 
-	return convertJiraIssue(issue, p.cfg.Source, p.cfg.APIURL), nil
+	return p.convert(issue), nil
+}
+
+// searchPageSize bounds how many issues a single search request asks for.
+// Jira caps full-field searches at 100 per page regardless of maxResults.
+const searchPageSize = 100
+
+// QueryResult is a page of tickets along with the cursor that resumes the
+// listing. NextPageToken is empty once Jira reports no further results.
+type QueryResult struct {
+	Tickets       []schema.Ticket `json:"tickets"`
+	NextPageToken string          `json:"nextPageToken,omitempty"`
 }
 
-// Query searches for Jira issues using JQL.
+// Query limits. A query without a limit returns defaultQueryLimit tickets,
+// and no query returns more than maxQueryLimit; larger listings are resumed
+// through QueryPage's cursor.
+const (
+	defaultQueryLimit = 50
+	maxQueryLimit     = 1000
+)
+
+// Query searches for Jira issues using JQL, following nextPageToken until
+// q.Limit tickets (defaultQueryLimit when unset, at most maxQueryLimit) are
+// collected or the results run out.
 func (p *JiraProvider) Query(ctx context.Context, q schema.TicketQuery) ([]schema.Ticket, error) {
-	jql := buildJQL(q, p.cfg.ProjectKey)
+	res, err := p.QueryPage(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return res.Tickets, nil
+}
 
-	// Use POST /rest/api/3/search/jql for JQL queries
-	reqURL := p.cfg.APIURL + "/rest/api/3/search/jql"
+// QueryPage behaves like Query but also returns the continuation cursor so a
+// large listing can be resumed. A cursor from a previous call is passed back
+// in q.Metadata["pageToken"].
+func (p *JiraProvider) QueryPage(ctx context.Context, q schema.TicketQuery) (QueryResult, error) {
+	q.Statuses = p.mapStatuses(q.Statuses)
+	jql := buildJQL(q, p.cfg.ProjectKey)
 
-	payload := map[string]any{
-		"jql":        jql,
-		"maxResults": 50,
-		"fields":     []string{"*all"},
+	var pageToken string
+	if v, ok := q.Metadata["pageToken"].(string); ok {
+		pageToken = v
 	}
-	if q.Limit > 0 {
-		payload["maxResults"] = q.Limit
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
 	}
+	limit = min(limit, maxQueryLimit)
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("marshal query payload: %w", err)
+	tickets := []schema.Ticket{}
+	for {
+		remaining := limit - len(tickets)
+		if remaining <= 0 {
+			break
+		}
+		pageSize := min(remaining, searchPageSize)
+
+		page, err := p.searchPage(ctx, jql, pageToken, pageSize)
+		if err != nil {
+			return QueryResult{}, err
+		}
+		for _, issue := range page.Issues {
+			tickets = append(tickets, p.convert(issue))
+		}
+
+		pageToken = page.NextPageToken
+		if page.IsLast || pageToken == "" || len(page.Issues) == 0 {
+			pageToken = ""
+			break
+		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+	return QueryResult{Tickets: tickets, NextPageToken: pageToken}, nil
+}
+
+// searchResponse is a single page returned by POST /rest/api/3/search/jql.
+type searchResponse struct {
+	Issues        []jiraIssue `json:"issues"`
+	NextPageToken string      `json:"nextPageToken"`
+	IsLast        bool        `json:"isLast"`
+}
+
+func (p *JiraProvider) searchPage(ctx context.Context, jql, pageToken string, maxResults int) (searchResponse, error) {
+	if p.cfg.dataCenter() {
+		return p.searchPageOffset(ctx, jql, pageToken, maxResults)
 	}
 
-	req.SetBasicAuth(p.cfg.Email, p.cfg.APIToken)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+	payload := map[string]any{
+		"jql":        jql,
+		"maxResults": maxResults,
+		"fields":     []string{"*all"},
+	}
+	if pageToken != "" {
+		payload["nextPageToken"] = pageToken
+	}
 
-	resp, err := p.client.Do(req)
+	// Use POST /rest/api/3/search/jql for JQL queries; the search is
+	// read-only so it is safe to retry like a GET.
+	resp, err := p.do(ctx, apiRequest{
+		method:     http.MethodPost,
+		path:       "/rest/api/3/search/jql",
+		body:       payload,
+		idempotent: true,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("execute request: %w", err)
+		return searchResponse{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("jira api error: %d %s", resp.StatusCode, string(bodyBytes))
+		return searchResponse{}, newAPIError(resp)
 	}
 
-	var result struct {
-		Issues []jiraIssue `json:"issues"`
-	}
+	var result searchResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+		return searchResponse{}, fmt.Errorf("decode response: %w", err)
 	}
-
-	tickets := make([]schema.Ticket, len(result.Issues))
-	for i, issue := range result.Issues {
-		tickets[i] = convertJiraIssue(issue, p.cfg.Source, p.cfg.APIURL)
-	}
-
-	return tickets, nil
+	return result, nil
 }
 
 func buildJQL(q schema.TicketQuery, projectKey string) string {
@@ -311,13 +713,9 @@ func buildJQL(q schema.TicketQuery, projectKey string) string {
 		clauses = append(clauses, fmt.Sprintf("text ~ \"%s\"", escapeJQL(q.Query)))
 	}
 
-	// Status filter
+	// Status filter: names, or normalised states matched by category
 	if len(q.Statuses) > 0 {
-		statuses := make([]string, len(q.Statuses))
-		for i, s := range q.Statuses {
-			statuses[i] = fmt.Sprintf("\"%s\"", escapeJQL(s))
-		}
-		clauses = append(clauses, fmt.Sprintf("status IN (%s)", strings.Join(statuses, ",")))
+		clauses = append(clauses, statusClause(q.Statuses))
 	}
 
 	// Assignee filter
@@ -346,8 +744,18 @@ func escapeJQL(s string) string {
 	return strings.ReplaceAll(s, "\"", "\\\"")
 }
 
-// Update modifies a Jira issue.
+// Update modifies a Jira issue. With an outbox configured, an update that
+// cannot reach Jira is queued behind any pending writes; id may be the
+// provisional ID of a queued create.
 func (p *JiraProvider) Update(ctx context.Context, id string, in schema.UpdateTicketInput) (schema.Ticket, error) {
+	op := outboxUpdate
+	if in.Status != nil && in.Title == nil && in.Description == nil && in.Assignees == nil && len(in.Fields) == 0 {
+		op = outboxTransition
+	}
+	return p.write(ctx, outboxEntry{Key: idempotencyKey(in.Metadata), Op: op, TicketID: id, Update: &in})
+}
+
+func (p *JiraProvider) update(ctx context.Context, id string, in schema.UpdateTicketInput) (schema.Ticket, error) {
 	payload := map[string]any{
 		"fields": map[string]any{},
 	}
@@ -357,39 +765,23 @@ func (p *JiraProvider) Update(ctx context.Context, id string, in schema.UpdateTi
 	}
 
 	if in.Description != nil {
-		payload["fields"].(map[string]any)["description"] = map[string]any{
-			"type":    "doc",
-			"version": 1,
-			"content": []map[string]any{
-				{
-					"type": "paragraph",
-					"content": []map[string]any{
-						{
-							"type": "text",
-							"text": *in.Description,
-						},
-					},
-				},
-			},
-		}
+		payload["fields"].(map[string]any)["description"] = p.descriptionValue(ctx, *in.Description)
 	}
 
 	if in.Assignees != nil && len(*in.Assignees) > 0 {
 		// Jira only supports single assignee, use first one
-		payload["fields"].(map[string]any)["assignee"] = map[string]string{
-			"accountId": (*in.Assignees)[0],
+		payload["fields"].(map[string]any)["assignee"] = p.userRef((*in.Assignees)[0])
+	}
+
+	// Handle priority, translating OpsOrch severities through the mapping
+	if priority := p.priorityName(in.Fields, in.Metadata); priority != "" {
+		payload["fields"].(map[string]any)["priority"] = map[string]string{
+			"name": priority,
 		}
 	}
 
 	// Add custom fields if provided
 	if in.Fields != nil {
-		// Handle priority
-		if priority, ok := in.Fields["priority"].(string); ok && priority != "" {
-			payload["fields"].(map[string]any)["priority"] = map[string]string{
-				"name": priority,
-			}
-		}
-
 		// Handle labels
 		if labels, ok := in.Fields["labels"].([]string); ok {
 			payload["fields"].(map[string]any)["labels"] = labels
@@ -422,7 +814,7 @@ func (p *JiraProvider) Update(ctx context.Context, id string, in schema.UpdateTi
 
 		// Add any other custom fields not handled above
 		for k, v := range in.Fields {
-			if k != "priority" && k != "labels" && k != "components" {
+			if k != "priority" && k != "labels" && k != "components" && !transitionField(k) {
 				payload["fields"].(map[string]any)[k] = v
 			}
 		}
@@ -430,40 +822,21 @@ func (p *JiraProvider) Update(ctx context.Context, id string, in schema.UpdateTi
 
 	// Handle status transitions separately if provided
 	if in.Status != nil {
-		if err := p.transitionIssue(ctx, id, *in.Status); err != nil {
+		if err := p.transitionIssue(ctx, id, p.statusMapping().toJira(*in.Status), transitionInputFrom(in.Fields)); err != nil {
 			return schema.Ticket{}, fmt.Errorf("transition issue: %w", err)
 		}
 	}
 
 	// Only send update if there are fields to update
 	if len(payload["fields"].(map[string]any)) > 0 {
-		body, err := json.Marshal(payload)
-		if err != nil {
-			return schema.Ticket{}, fmt.Errorf("marshal update payload: %w", err)
-		}
-
-		req, err := http.NewRequestWithContext(ctx, "PUT", p.cfg.APIURL+"/rest/api/3/issue/"+id, bytes.NewReader(body))
-		if err != nil {
-			return schema.Ticket{}, fmt.Errorf("create request: %w", err)
-		}
-
-		req.SetBasicAuth(p.cfg.Email, p.cfg.APIToken)
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Accept", "application/json")
-
-		resp, err := p.client.Do(req)
+		resp, err := p.do(ctx, apiRequest{method: http.MethodPut, path: p.apiPath("/issue/" + id), body: payload})
 		if err != nil {
-			return schema.Ticket{}, fmt.Errorf("execute request: %w", err)
+			return schema.Ticket{}, err
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode == http.StatusNotFound {
-			return schema.Ticket{}, errNotFound
-		}
-
 		if resp.StatusCode != http.StatusNoContent {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			return schema.Ticket{}, fmt.Errorf("jira api error: %d %s", resp.StatusCode, string(bodyBytes))
+			return schema.Ticket{}, newAPIError(resp)
 		}
 	}
 
@@ -471,83 +844,70 @@ func (p *JiraProvider) Update(ctx context.Context, id string, in schema.UpdateTi
 	return p.Get(ctx, id)
 }
 
-func (p *JiraProvider) transitionIssue(ctx context.Context, id string, targetStatus string) error {
-	// Get available transitions
-	req, err := http.NewRequestWithContext(ctx, "GET", p.cfg.APIURL+"/rest/api/3/issue/"+id+"/transitions", nil)
+// transitionIssue moves an issue to targetStatus. A transition available
+// from the current status is taken directly; otherwise the shortest path
+// through the workflow is run one step at a time, checking each step
+// against the transitions Jira offers at that point. in supplies the
+// screen fields and comment the transitions need.
+func (p *JiraProvider) transitionIssue(ctx context.Context, id string, targetStatus string, in *transitionInput) error {
+	available, err := p.transitions(ctx, id)
 	if err != nil {
-		return fmt.Errorf("create transitions request: %w", err)
+		return err
 	}
-
-	req.SetBasicAuth(p.cfg.Email, p.cfg.APIToken)
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("execute transitions request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("get transitions error: %d %s", resp.StatusCode, string(bodyBytes))
+	if t, ok := matchTransition(available, targetStatus); ok {
+		return p.runTransition(ctx, id, t, in, true)
 	}
 
-	var transitionsResp struct {
-		Transitions []struct {
-			ID   string `json:"id"`
-			Name string `json:"name"`
-			To   struct {
-				Name string `json:"name"`
-			} `json:"to"`
-		} `json:"transitions"`
+	path, current, err := p.transitionPath(ctx, id, targetStatus)
+	if errors.Is(err, errNoWorkflow) {
+		return p.walkTransitions(ctx, id, targetStatus, available, in)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&transitionsResp); err != nil {
-		return fmt.Errorf("decode transitions: %w", err)
+	if err != nil {
+		return err
 	}
-
-	// Find transition that leads to target status
-	var transitionID string
-	for _, t := range transitionsResp.Transitions {
-		if strings.EqualFold(t.To.Name, targetStatus) {
-			transitionID = t.ID
-			break
+	for i, step := range path {
+		if i > 0 {
+			if available, err = p.transitions(ctx, id); err != nil {
+				return fmt.Errorf("transition to %s stopped in status %q after %d of %d steps: %w", targetStatus, current, i, len(path), err)
+			}
 		}
+		t, ok := step.match(available)
+		if !ok {
+			err = fmt.Errorf("%w: transition %q is not available", ErrValidation, step.name)
+		} else {
+			err = p.runTransition(ctx, id, t, in, i == len(path)-1)
+		}
+		if err != nil {
+			return fmt.Errorf("transition to %s stopped in status %q after %d of %d steps: %w", targetStatus, current, i, len(path), err)
+		}
+		current = t.To.Name
 	}
+	return nil
+}
 
-	if transitionID == "" {
-		return fmt.Errorf("no transition found to status: %s", targetStatus)
-	}
-
-	// Execute transition
-	transitionPayload := map[string]any{
-		"transition": map[string]string{
-			"id": transitionID,
-		},
-	}
-
-	body, err := json.Marshal(transitionPayload)
-	if err != nil {
-		return fmt.Errorf("marshal transition payload: %w", err)
-	}
-
-	req, err = http.NewRequestWithContext(ctx, "POST", p.cfg.APIURL+"/rest/api/3/issue/"+id+"/transitions", bytes.NewReader(body))
+// runTransition executes one transition. One whose screen requires fields
+// in does not supply is refused up front rather than sent to fail with a
+// 400.
+func (p *JiraProvider) runTransition(ctx context.Context, id string, t jiraTransition, in *transitionInput, last bool) error {
+	transitionPayload, err := in.payload(ctx, p, t, last)
 	if err != nil {
-		return fmt.Errorf("create transition request: %w", err)
+		return err
 	}
 
-	req.SetBasicAuth(p.cfg.Email, p.cfg.APIToken)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	resp, err = p.client.Do(req)
+	// Transitions are not idempotent: a replay after an ambiguous failure
+	// could move the issue twice, so only throttled attempts are retried.
+	resp, err := p.do(ctx, apiRequest{
+		method: http.MethodPost,
+		path:   p.apiPath("/issue/" + id + "/transitions"),
+		body:   transitionPayload,
+	})
 	if err != nil {
-		return fmt.Errorf("execute transition request: %w", err)
+		return fmt.Errorf("execute transition: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("transition error: %d %s", resp.StatusCode, string(bodyBytes))
+		return fmt.Errorf("execute transition: %w", newAPIError(resp))
 	}
 
 	return nil
@@ -558,17 +918,15 @@ type jiraIssue struct {
 	ID     string `json:"id"`
 	Key    string `json:"key"`
 	Fields struct {
-		Summary     string `json:"summary"`
-		Description struct {
-			Content []struct {
-				Content []struct {
-					Text string `json:"text"`
-				} `json:"content"`
-			} `json:"content"`
-		} `json:"description"`
-		Status struct {
-			Name string `json:"name"`
+		Summary     string          `json:"summary"`
+		Description jiraDescription `json:"description"`
+		Status      struct {
+			Name           string             `json:"name"`
+			StatusCategory jiraStatusCategory `json:"statusCategory"`
 		} `json:"status"`
+		Resolution *struct {
+			Name string `json:"name"`
+		} `json:"resolution"`
 		Priority *struct {
 			ID   string `json:"id"`
 			Name string `json:"name"`
@@ -583,19 +941,92 @@ type jiraIssue struct {
 			ID   string `json:"id"`
 			Name string `json:"name"`
 		} `json:"components"`
-		Assignee *struct {
-			AccountID   string `json:"accountId"`
-			DisplayName string `json:"displayName"`
-		} `json:"assignee"`
-		Reporter *struct {
-			AccountID   string `json:"accountId"`
-			DisplayName string `json:"displayName"`
-		} `json:"reporter"`
-		Created string `json:"created"`
-		Updated string `json:"updated"`
+		Attachment []jiraAttachment `json:"attachment"`
+		Assignee   *jiraUser        `json:"assignee"`
+		Reporter   *jiraUser        `json:"reporter"`
+		Created    string           `json:"created"`
+		Updated    string           `json:"updated"`
+		// Lifecycle dates; duedate is a bare date.
+		ResolutionDate           string `json:"resolutiondate"`
+		DueDate                  string `json:"duedate"`
+		StatusCategoryChangeDate string `json:"statuscategorychangedate"`
+		LastViewed               string `json:"lastViewed"`
 	} `json:"fields"`
 }
 
+// jiraDescription holds either a Cloud ADF document or a Data Center
+// wiki-markup string.
+type jiraDescription struct {
+	// Doc is the decoded ADF tree and Raw the exact JSON it came from.
+	Doc *adfNode
+	Raw json.RawMessage
+	// Text is the raw description when Jira returned a plain string.
+	Text string
+}
+
+func (d *jiraDescription) UnmarshalJSON(data []byte) error {
+	switch {
+	case len(data) == 0 || string(data) == "null":
+		return nil
+	case data[0] == '"':
+		return json.Unmarshal(data, &d.Text)
+	}
+	var doc adfNode
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	d.Doc = &doc
+	d.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// format reports how the body is written: "markdown" when it was rendered
+// from ADF, "wiki" for Data Center markup and empty when there is none.
+func (d jiraDescription) format() string {
+	switch {
+	case d.Doc != nil:
+		return "markdown"
+	case d.Text != "":
+		return "wiki"
+	}
+	return ""
+}
+
+// markdown returns the ADF document rendered as Markdown, or the wiki text.
+func (d jiraDescription) markdown() string {
+	if d.Doc != nil {
+		return renderADFMarkdown(*d.Doc)
+	}
+	return d.Text
+}
+
+// text returns a plain-text rendering of the body.
+func (d jiraDescription) text() string {
+	if d.Doc != nil {
+		return renderADFText(*d.Doc)
+	}
+	return d.Text
+}
+
+// jiraUser covers both Cloud (accountId) and Data Center (name/key) users.
+type jiraUser struct {
+	AccountID   string `json:"accountId"`
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	DisplayName string `json:"displayName"`
+}
+
+// ID returns the identifier Jira accepts when referencing this user.
+func (u *jiraUser) ID() string {
+	if u.AccountID != "" {
+		return u.AccountID
+	}
+	if u.Name != "" {
+		return u.Name
+	}
+	return u.Key
+}
+
 func convertJiraIssue(issue jiraIssue, source string, apiURL string) schema.Ticket {
 	ticket := schema.Ticket{
 		ID:       issue.ID,
@@ -606,26 +1037,38 @@ func convertJiraIssue(issue jiraIssue, source string, apiURL string) schema.Tick
 		Metadata: map[string]any{"source": source},
 	}
 
-	// Extract description text
-	var descParts []string
-	for _, content := range issue.Fields.Description.Content {
-		for _, textContent := range content.Content {
-			if textContent.Text != "" {
-				descParts = append(descParts, textContent.Text)
-			}
+	// Render the description: ADF becomes Markdown, with a plain-text
+	// variant and the original document kept for lossless round-tripping.
+	if desc := issue.Fields.Description; desc.format() != "" {
+		ticket.Description = desc.markdown()
+		ticket.Metadata["description_format"] = desc.format()
+		if desc.Doc != nil {
+			ticket.Metadata["description_text"] = desc.text()
+			ticket.Metadata["description_adf"] = desc.Raw
 		}
 	}
-	ticket.Description = strings.Join(descParts, " ")
+
+	// Normalise the state from the status category so open/closed means
+	// the same across workflows
+	if category := issue.Fields.Status.StatusCategory; category.Key != "" {
+		ticket.Metadata["status_category"] = category.Key
+	}
+	if issue.Fields.Resolution != nil {
+		ticket.Metadata["resolution"] = issue.Fields.Resolution.Name
+	}
+	if state := normalizedState(issue.Fields.Status.StatusCategory.Key, issue.Fields.Resolution != nil); state != "" {
+		ticket.Metadata["state"] = state
+	}
 
 	// Extract assignees
 	if issue.Fields.Assignee != nil {
-		ticket.Assignees = []string{issue.Fields.Assignee.AccountID}
+		ticket.Assignees = []string{issue.Fields.Assignee.ID()}
 		ticket.Metadata["assignee_name"] = issue.Fields.Assignee.DisplayName
 	}
 
 	// Extract reporter
 	if issue.Fields.Reporter != nil {
-		ticket.Reporter = issue.Fields.Reporter.AccountID
+		ticket.Reporter = issue.Fields.Reporter.ID()
 		ticket.Metadata["reporter_name"] = issue.Fields.Reporter.DisplayName
 	}
 
@@ -662,13 +1105,33 @@ func convertJiraIssue(issue jiraIssue, source string, apiURL string) schema.Tick
 		ticket.Metadata["component_details"] = componentDetails
 	}
 
+	// Extract attachment metadata; content is fetched on demand.
+	if len(issue.Fields.Attachment) > 0 {
+		ticket.Metadata["attachments"] = convertJiraAttachments(issue.Fields.Attachment)
+	}
+
 	// Parse timestamps
-	if createdAt, err := time.Parse(time.RFC3339, issue.Fields.Created); err == nil {
+	if createdAt, ok := parseJiraTime(issue.Fields.Created); ok {
 		ticket.CreatedAt = createdAt
 	}
-	if updatedAt, err := time.Parse(time.RFC3339, issue.Fields.Updated); err == nil {
+	if updatedAt, ok := parseJiraTime(issue.Fields.Updated); ok {
 		ticket.UpdatedAt = updatedAt
 	}
 
+	// Lifecycle dates, kept as time.Time so time-to-resolve can be computed
+	lifecycle := []struct {
+		key, value string
+	}{
+		{"resolved_at", issue.Fields.ResolutionDate},
+		{"due_date", issue.Fields.DueDate},
+		{"status_category_changed_at", issue.Fields.StatusCategoryChangeDate},
+		{"last_viewed_at", issue.Fields.LastViewed},
+	}
+	for _, d := range lifecycle {
+		if t, ok := parseJiraTime(d.value); ok {
+			ticket.Metadata[d.key] = t
+		}
+	}
+
 	return ticket
 }
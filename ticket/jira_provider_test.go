@@ -3,8 +3,11 @@ package ticket
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -101,6 +104,26 @@ func TestNew(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		{
+			name: "datacenter without email",
+			config: map[string]any{
+				"apiToken":   "test-pat",
+				"apiURL":     "https://jira.example.com",
+				"projectKey": "PROJ",
+				"deployment": "datacenter",
+			},
+			expectErr: false,
+		},
+		{
+			name: "unknown deployment",
+			config: map[string]any{
+				"apiToken":   "test-token",
+				"email":      "test@example.com",
+				"projectKey": "PROJ",
+				"deployment": "onprem",
+			},
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -329,8 +352,8 @@ func TestGet(t *testing.T) {
 
 	t.Run("get non-existent ticket", func(t *testing.T) {
 		_, err := p.Get(ctx, "NOTFOUND")
-		if err != errNotFound {
-			t.Errorf("Get() error = %v, want errNotFound", err)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get() error = %v, want ErrNotFound", err)
 		}
 	})
 }
@@ -523,6 +546,120 @@ func TestQuery(t *testing.T) {
 	})
 }
 
+func TestQueryPagination(t *testing.T) {
+	var tokens []string
+	total, perPage := 5, 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/3/search/jql" || r.Method != "POST" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		token, _ := payload["nextPageToken"].(string)
+		maxResults := int(payload["maxResults"].(float64))
+		tokens = append(tokens, token)
+
+		// Serve total issues, at most perPage per page like a Jira that
+		// returns fewer than maxResults, resuming from the numeric offset in
+		// the token.
+		if maxResults > perPage {
+			maxResults = perPage
+		}
+		start := 0
+		if token != "" {
+			fmt.Sscanf(token, "page-%d", &start)
+		}
+		end := start + maxResults
+		if end > total {
+			end = total
+		}
+		issues := []map[string]any{}
+		for i := start; i < end; i++ {
+			issues = append(issues, map[string]any{
+				"id":     fmt.Sprintf("%d", 10000+i),
+				"key":    fmt.Sprintf("PROJ-%d", i+1),
+				"fields": map[string]any{"summary": "Task", "status": map[string]any{"name": "To Do"}},
+			})
+		}
+		resp := map[string]any{"issues": issues, "isLast": end >= total}
+		if end < total {
+			resp["nextPageToken"] = fmt.Sprintf("page-%d", end)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{
+		cfg:    Config{Source: "jira", APIURL: server.URL, ProjectKey: "PROJ"},
+		client: &http.Client{},
+	}
+	ctx := context.Background()
+
+	t.Run("follows nextPageToken until isLast", func(t *testing.T) {
+		tokens = nil
+		tickets, err := p.Query(ctx, schema.TicketQuery{})
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		if len(tickets) != 5 {
+			t.Errorf("len(tickets) = %v, want 5", len(tickets))
+		}
+		if want := []string{"", "page-2", "page-4"}; !reflect.DeepEqual(tokens, want) {
+			t.Errorf("page tokens = %q, want %q", tokens, want)
+		}
+		for i, ticket := range tickets {
+			if want := fmt.Sprintf("PROJ-%d", i+1); ticket.Key != want {
+				t.Errorf("tickets[%d].Key = %v, want %v", i, ticket.Key, want)
+			}
+		}
+	})
+
+	t.Run("stops at limit and returns cursor", func(t *testing.T) {
+		tokens = nil
+		res, err := p.QueryPage(ctx, schema.TicketQuery{Limit: 3})
+		if err != nil {
+			t.Fatalf("QueryPage() error = %v", err)
+		}
+		if len(res.Tickets) != 3 {
+			t.Errorf("len(tickets) = %v, want 3", len(res.Tickets))
+		}
+		if res.NextPageToken != "page-3" {
+			t.Errorf("NextPageToken = %v, want page-3", res.NextPageToken)
+		}
+
+		next, err := p.QueryPage(ctx, schema.TicketQuery{Metadata: map[string]any{"pageToken": res.NextPageToken}})
+		if err != nil {
+			t.Fatalf("QueryPage() resume error = %v", err)
+		}
+		if len(next.Tickets) != 2 || next.Tickets[0].Key != "PROJ-4" {
+			t.Errorf("resumed tickets = %v, want PROJ-4 and PROJ-5", next.Tickets)
+		}
+		if next.NextPageToken != "" {
+			t.Errorf("NextPageToken = %v, want empty", next.NextPageToken)
+		}
+	})
+	t.Run("bounds queries without a limit", func(t *testing.T) {
+		total, perPage = 1500, searchPageSize
+		defer func() { total, perPage = 5, 2 }()
+
+		res, err := p.QueryPage(ctx, schema.TicketQuery{})
+		if err != nil {
+			t.Fatalf("QueryPage() error = %v", err)
+		}
+		if len(res.Tickets) != defaultQueryLimit || res.NextPageToken == "" {
+			t.Errorf("QueryPage() returned %d tickets, cursor %q, want %d and a cursor", len(res.Tickets), res.NextPageToken, defaultQueryLimit)
+		}
+		res, err = p.QueryPage(ctx, schema.TicketQuery{Limit: 5000})
+		if err != nil {
+			t.Fatalf("QueryPage() error = %v", err)
+		}
+		if len(res.Tickets) != maxQueryLimit || res.NextPageToken == "" {
+			t.Errorf("QueryPage() returned %d tickets, want at most %d", len(res.Tickets), maxQueryLimit)
+		}
+	})
+}
+
 func TestUpdate(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/rest/api/3/issue/PROJ-1" && r.Method == "PUT" {
@@ -666,8 +803,8 @@ func TestUpdate(t *testing.T) {
 		_, err := p.Update(ctx, "NOTFOUND", schema.UpdateTicketInput{
 			Title: &newTitle,
 		})
-		if err != errNotFound {
-			t.Errorf("Update() error = %v, want errNotFound", err)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Update() error = %v, want ErrNotFound", err)
 		}
 	})
 }
@@ -693,6 +830,16 @@ func TestBuildJQL(t *testing.T) {
 			query:    schema.TicketQuery{Statuses: []string{"To Do", "In Progress"}},
 			expected: "project = PROJ AND status IN (\"To Do\",\"In Progress\") ORDER BY key DESC",
 		},
+		{
+			name:     "with normalised states",
+			query:    schema.TicketQuery{Statuses: []string{"open", "in_progress"}},
+			expected: "project = PROJ AND statusCategory IN (\"To Do\",\"In Progress\") ORDER BY key DESC",
+		},
+		{
+			name:     "with states and status names",
+			query:    schema.TicketQuery{Statuses: []string{"closed", "Won't Do"}},
+			expected: "project = PROJ AND (status IN (\"Won't Do\") OR statusCategory IN (\"Done\")) ORDER BY key DESC",
+		},
 		{
 			name:     "with assignee filter",
 			query:    schema.TicketQuery{Assignees: []string{"alice", "bob"}},
@@ -756,31 +903,17 @@ func TestConvertJiraIssue(t *testing.T) {
 	}
 	issue.Fields.Summary = "Test issue"
 	issue.Fields.Status.Name = "To Do"
-	issue.Fields.Description.Content = []struct {
-		Content []struct {
-			Text string `json:"text"`
-		} `json:"content"`
-	}{
-		{
-			Content: []struct {
-				Text string `json:"text"`
-			}{
-				{Text: "First paragraph"},
-				{Text: "Second paragraph"},
-			},
-		},
+	if err := json.Unmarshal([]byte(`{"type":"doc","version":1,"content":[
+		{"type":"paragraph","content":[{"type":"text","text":"First paragraph"}]},
+		{"type":"paragraph","content":[{"type":"text","text":"Second paragraph"}]}
+	]}`), &issue.Fields.Description); err != nil {
+		t.Fatalf("unmarshal description: %v", err)
 	}
-	issue.Fields.Assignee = &struct {
-		AccountID   string `json:"accountId"`
-		DisplayName string `json:"displayName"`
-	}{
+	issue.Fields.Assignee = &jiraUser{
 		AccountID:   "user123",
 		DisplayName: "Alice",
 	}
-	issue.Fields.Reporter = &struct {
-		AccountID   string `json:"accountId"`
-		DisplayName string `json:"displayName"`
-	}{
+	issue.Fields.Reporter = &jiraUser{
 		AccountID:   "user456",
 		DisplayName: "Bob",
 	}
@@ -801,6 +934,9 @@ func TestConvertJiraIssue(t *testing.T) {
 		{ID: "10000", Name: "Frontend"},
 		{ID: "10001", Name: "Backend"},
 	}
+	issue.Fields.Attachment = []jiraAttachment{
+		{ID: "300", Filename: "error.log", MIMEType: "text/plain", Size: 2048},
+	}
 	issue.Fields.Created = "2025-11-21T10:00:00Z"
 	issue.Fields.Updated = "2025-11-21T11:00:00Z"
 
@@ -821,8 +957,14 @@ func TestConvertJiraIssue(t *testing.T) {
 	if ticket.URL != "https://example.atlassian.net/browse/PROJ-1" {
 		t.Errorf("URL = %v, want https://example.atlassian.net/browse/PROJ-1", ticket.URL)
 	}
-	if ticket.Description != "First paragraph Second paragraph" {
-		t.Errorf("Description = %v, want First paragraph Second paragraph", ticket.Description)
+	if ticket.Description != "First paragraph\n\nSecond paragraph" {
+		t.Errorf("Description = %q, want two Markdown paragraphs", ticket.Description)
+	}
+	if _, ok := ticket.Metadata["description_adf"].(json.RawMessage); !ok {
+		t.Errorf("Metadata[description_adf] = %T, want json.RawMessage", ticket.Metadata["description_adf"])
+	}
+	if atts, ok := ticket.Metadata["attachments"].([]Attachment); !ok || len(atts) != 1 || atts[0].Filename != "error.log" || atts[0].Size != 2048 {
+		t.Errorf("Metadata[attachments] = %v, want error.log metadata", ticket.Metadata["attachments"])
 	}
 	if len(ticket.Assignees) != 1 || ticket.Assignees[0] != "user123" {
 		t.Errorf("Assignees = %v, want [user123]", ticket.Assignees)
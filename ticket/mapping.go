@@ -0,0 +1,135 @@
+package ticket
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// mappingDefault is the project key of the table that applies to every
+// project; a project's own table overrides it entry by entry.
+const mappingDefault = "*"
+
+// valueMap is one direction-agnostic table for the configured project,
+// keyed by lower-cased OpsOrch value.
+type valueMap map[string]string
+
+// projectMapping merges the default table with the one for project.
+func projectMapping(tables map[string]map[string]string, project string) valueMap {
+	if len(tables) == 0 {
+		return nil
+	}
+	out := valueMap{}
+	for _, name := range []string{mappingDefault, project} {
+		for from, to := range tables[name] {
+			out[strings.ToLower(strings.TrimSpace(from))] = to
+		}
+	}
+	return out
+}
+
+// toJira translates an OpsOrch value; unmapped values pass through as
+// literal Jira names.
+func (m valueMap) toJira(v string) string {
+	if to, ok := m[strings.ToLower(strings.TrimSpace(v))]; ok {
+		return to
+	}
+	return v
+}
+
+// fromJira finds the OpsOrch value for a Jira name. When several values map
+// to the same name the alphabetically first wins, so reads are stable.
+func (m valueMap) fromJira(name string) (string, bool) {
+	var matches []string
+	for from, to := range m {
+		if strings.EqualFold(to, name) {
+			matches = append(matches, from)
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+	sort.Strings(matches)
+	return matches[0], true
+}
+
+func (p *JiraProvider) statusMapping() valueMap {
+	return projectMapping(p.cfg.StatusMapping, p.cfg.ProjectKey)
+}
+
+func (p *JiraProvider) priorityMapping() valueMap {
+	return projectMapping(p.cfg.PriorityMapping, p.cfg.ProjectKey)
+}
+
+// priorityName returns the Jira priority for a write: fields["priority"],
+// else metadata["severity"], translated through the priority mapping.
+func (p *JiraProvider) priorityName(fields, metadata map[string]any) string {
+	v, _ := fields["priority"].(string)
+	if v == "" {
+		v, _ = metadata["severity"].(string)
+	}
+	if v == "" {
+		return ""
+	}
+	return p.priorityMapping().toJira(v)
+}
+
+// mapStatuses translates OpsOrch states in a query into Jira status names.
+func (p *JiraProvider) mapStatuses(statuses []string) []string {
+	m := p.statusMapping()
+	if len(m) == 0 {
+		return statuses
+	}
+	out := make([]string, len(statuses))
+	for i, s := range statuses {
+		out[i] = m.toJira(s)
+	}
+	return out
+}
+
+// convert normalises an issue and applies the mapping tables in reverse:
+// a mapped status replaces Status (the Jira name is kept in
+// metadata["jira_status"]) and a mapped priority sets metadata["severity"].
+func (p *JiraProvider) convert(issue jiraIssue) schema.Ticket {
+	t := convertJiraIssue(issue, p.cfg.Source, p.cfg.APIURL)
+	if v, ok := p.statusMapping().fromJira(t.Status); ok {
+		t.Metadata["jira_status"] = t.Status
+		t.Status = v
+	}
+	if issue.Fields.Priority != nil {
+		if v, ok := p.priorityMapping().fromJira(issue.Fields.Priority.Name); ok {
+			t.Metadata["severity"] = v
+		}
+	}
+	return t
+}
+
+// mappingTables reads {"PROJ": {"from": "to"}} tables from config. A flat
+// {"from": "to"} map is taken as the default table.
+func mappingTables(v any) map[string]map[string]string {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) == 0 {
+		return nil
+	}
+	out := map[string]map[string]string{}
+	for key, value := range m {
+		switch value := value.(type) {
+		case string:
+			if out[mappingDefault] == nil {
+				out[mappingDefault] = map[string]string{}
+			}
+			out[mappingDefault][key] = value
+		case map[string]any:
+			if out[key] == nil {
+				out[key] = map[string]string{}
+			}
+			for from, to := range value {
+				if s, ok := to.(string); ok {
+					out[key][from] = s
+				}
+			}
+		}
+	}
+	return out
+}
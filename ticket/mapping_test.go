@@ -0,0 +1,105 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+func TestUpdateAppliesMappings(t *testing.T) {
+	var mu sync.Mutex
+	var priority, transition string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/rest/api/3/issue/OPS-1":
+			var payload struct {
+				Fields struct {
+					Priority struct {
+						Name string `json:"name"`
+					} `json:"priority"`
+				} `json:"fields"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			priority = payload.Fields.Priority.Name
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/3/issue/OPS-1/transitions":
+			w.Write([]byte(`{"transitions":[{"id":"21","name":"Start","to":{"name":"In Progress"}},{"id":"31","name":"Close out","to":{"name":"Closed"}}]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/3/issue/OPS-1/transitions":
+			var payload struct {
+				Transition struct {
+					ID string `json:"id"`
+				} `json:"transition"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			transition = payload.Transition.ID
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/3/issue/OPS-1":
+			w.Write([]byte(`{"id":"10001","key":"OPS-1","fields":{"summary":"Outage","status":{"name":"Closed"},"priority":{"name":"Highest"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{
+		cfg: Config{
+			APIURL:     server.URL,
+			ProjectKey: "OPS",
+			Source:     "jira",
+			StatusMapping: mappingTables(map[string]any{
+				"*":   map[string]any{"resolved": "Done", "acknowledged": "In Progress"},
+				"OPS": map[string]any{"resolved": "Close out"},
+			}),
+			PriorityMapping: mappingTables(map[string]any{"sev1": "Highest", "sev2": "High"}),
+		},
+		client: &http.Client{},
+	}
+
+	status := "Resolved"
+	got, err := p.Update(context.Background(), "OPS-1", schema.UpdateTicketInput{
+		Status:   &status,
+		Metadata: map[string]any{"severity": "sev1"},
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if priority != "Highest" || transition != "31" {
+		t.Errorf("priority = %q, transition = %q, want Highest and the project's Close out transition", priority, transition)
+	}
+	if got.Status != "Closed" || got.Metadata["severity"] != "sev1" {
+		t.Errorf("Update() status = %q, severity = %v, want Closed unmapped and sev1", got.Status, got.Metadata["severity"])
+	}
+}
+
+func TestConvertAppliesStatusMapping(t *testing.T) {
+	p := &JiraProvider{cfg: Config{
+		Source:        "jira",
+		StatusMapping: mappingTables(map[string]any{"mitigated": "Monitoring", "resolved": "Done", "closed": "Done"}),
+	}}
+	var issue jiraIssue
+	issue.Key = "OPS-2"
+	issue.Fields.Status.Name = "Done"
+
+	got := p.convert(issue)
+	if got.Status != "closed" || got.Metadata["jira_status"] != "Done" {
+		t.Errorf("convert() status = %q, jira_status = %v, want closed (first of closed/resolved) and Done", got.Status, got.Metadata["jira_status"])
+	}
+}
+
+func TestMapStatuses(t *testing.T) {
+	p := &JiraProvider{cfg: Config{
+		ProjectKey:    "OPS",
+		StatusMapping: mappingTables(map[string]any{"acknowledged": "In Progress"}),
+	}}
+	got := p.mapStatuses([]string{"Acknowledged", "Blocked"})
+	if len(got) != 2 || got[0] != "In Progress" || got[1] != "Blocked" {
+		t.Errorf("mapStatuses() = %v, want [In Progress Blocked]", got)
+	}
+}
@@ -0,0 +1,611 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	mdHeading    = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdRule       = regexp.MustCompile(`^ {0,3}(?:(?:- *){3,}|(?:\* *){3,}|(?:_ *){3,})$`)
+	mdFence      = regexp.MustCompile("^ {0,3}(```+|~~~+)\\s*([\\w+#.-]*)")
+	mdListItem   = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])( +|$)(.*)$`)
+	mdTaskMarker = regexp.MustCompile(`^\[([ xX])\] +(.*)$`)
+	mdTableSep   = regexp.MustCompile(`^ *\|? *:?-+:? *(\| *:?-+:? *)*\|? *$`)
+	mdMention    = regexp.MustCompile(`^@([A-Za-z0-9][A-Za-z0-9._-]*(?:@[A-Za-z0-9.-]+\.[A-Za-z]+)?)`)
+)
+
+// markdownToADF converts Markdown into an ADF document. Supported syntax:
+// ATX headings, bullet/ordered/task lists (nested by indentation), fenced
+// code blocks with a language, GitHub tables, blockquotes, rules, links,
+// autolinks, bold, italic, strikethrough, inline code and @mentions. Single
+// newlines inside a paragraph are kept as hard breaks, since alert templates
+// rely on them for layout.
+//
+// Mentions are emitted as unresolved mention nodes carrying the handle in
+// attrs["query"]; resolveMentions turns them into real mentions or text.
+func markdownToADF(src string) adfNode {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\t", "    ")
+	return adfNode{Type: "doc", Content: parseMarkdownBlocks(strings.Split(src, "\n"))}
+}
+
+func parseMarkdownBlocks(lines []string) []adfNode {
+	var blocks []adfNode
+	var para []string
+
+	flush := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, markdownParagraph(para))
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+
+		case mdFence.MatchString(line):
+			flush()
+			m := mdFence.FindStringSubmatch(line)
+			fence := m[1]
+			var code []string
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					break
+				}
+				code = append(code, lines[i])
+			}
+			node := adfNode{Type: "codeBlock"}
+			if m[2] != "" {
+				node.Attrs = map[string]any{"language": m[2]}
+			}
+			if text := strings.Join(code, "\n"); text != "" {
+				node.Content = []adfNode{{Type: "text", Text: text}}
+			}
+			blocks = append(blocks, node)
+
+		case mdHeading.MatchString(trimmed):
+			flush()
+			m := mdHeading.FindStringSubmatch(trimmed)
+			blocks = append(blocks, adfNode{
+				Type:    "heading",
+				Attrs:   map[string]any{"level": len(m[1])},
+				Content: parseMarkdownInline(m[2], nil),
+			})
+
+		case mdRule.MatchString(line):
+			flush()
+			blocks = append(blocks, adfNode{Type: "rule"})
+
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			var quoted []string
+			for ; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if !strings.HasPrefix(t, ">") {
+					i--
+					break
+				}
+				t = strings.TrimPrefix(t, ">")
+				quoted = append(quoted, strings.TrimPrefix(t, " "))
+			}
+			blocks = append(blocks, adfNode{Type: "blockquote", Content: parseMarkdownBlocks(quoted)})
+
+		case strings.Contains(line, "|") && i+1 < len(lines) && mdTableSep.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "|"):
+			flush()
+			rows := []string{line}
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+				rows = append(rows, lines[i])
+			}
+			i--
+			blocks = append(blocks, markdownTable(rows))
+
+		case mdListItem.MatchString(line) && (len(para) == 0 || startsInterruptingList(line)):
+			flush()
+			var list adfNode
+			list, i = markdownList(lines, i)
+			i--
+			blocks = append(blocks, list)
+
+		default:
+			para = append(para, trimmed)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// startsBlock reports whether an unindented line opens a new block rather
+// than lazily continuing the paragraph of the current list item.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return mdListItem.MatchString(line) || mdHeading.MatchString(trimmed) || mdFence.MatchString(line) ||
+		mdRule.MatchString(line) || strings.HasPrefix(trimmed, ">") || strings.HasPrefix(trimmed, "|")
+}
+
+// startsInterruptingList reports whether a list marker may interrupt a
+// paragraph. As in CommonMark, only bullets and lists starting at 1 can, so
+// a wrapped line like "2024. was a year" stays in the paragraph.
+func startsInterruptingList(line string) bool {
+	m := mdListItem.FindStringSubmatch(line)
+	if m == nil || strings.TrimSpace(m[4]) == "" {
+		return false
+	}
+	marker := m[2]
+	return !isOrderedMarker(marker) || strings.TrimRight(marker, ".)") == "1"
+}
+
+func isOrderedMarker(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+// markdownList consumes the list starting at lines[start] and returns it
+// together with the index of the first line after it.
+func markdownList(lines []string, start int) (adfNode, int) {
+	first := mdListItem.FindStringSubmatch(lines[start])
+	baseIndent := len(first[1])
+	ordered := isOrderedMarker(first[2])
+
+	list := adfNode{Type: "bulletList"}
+	if ordered {
+		list.Type = "orderedList"
+		if n, _ := strconv.Atoi(strings.TrimRight(first[2], ".)")); n != 1 {
+			list.Attrs = map[string]any{"order": n}
+		}
+	}
+
+	type item struct {
+		lines []string
+		task  string
+	}
+	var items []item
+
+	i := start
+	for i < len(lines) {
+		line := lines[i]
+		if m := mdListItem.FindStringSubmatch(line); m != nil && len(m[1]) == baseIndent {
+			if isOrderedMarker(m[2]) != ordered {
+				break
+			}
+			it := item{lines: []string{m[4]}}
+			if tm := mdTaskMarker.FindStringSubmatch(m[4]); tm != nil && !ordered {
+				it.task = "TODO"
+				if tm[1] != " " {
+					it.task = "DONE"
+				}
+				it.lines[0] = tm[2]
+			}
+			items = append(items, it)
+			i++
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.TrimSpace(line) == "" {
+			// A blank line continues the list only if more indented
+			// content or another item follows.
+			next := i + 1
+			for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+				next++
+			}
+			if next >= len(lines) {
+				break
+			}
+			nextIndent := len(lines[next]) - len(strings.TrimLeft(lines[next], " "))
+			if m := mdListItem.FindStringSubmatch(lines[next]); nextIndent <= baseIndent && (m == nil || len(m[1]) != baseIndent) {
+				break
+			}
+			items[len(items)-1].lines = append(items[len(items)-1].lines, "")
+			i++
+			continue
+		}
+		if indent <= baseIndent && startsBlock(line) {
+			break
+		}
+		cut := indent
+		if cut > baseIndent+4 {
+			cut = baseIndent + 4
+		}
+		items[len(items)-1].lines = append(items[len(items)-1].lines, line[cut:])
+		i++
+	}
+
+	allTasks := len(items) > 0
+	for _, it := range items {
+		if it.task == "" {
+			allTasks = false
+		}
+	}
+
+	for n, it := range items {
+		if allTasks {
+			list.Type = "taskList"
+			list.Attrs = map[string]any{"localId": "tasks-" + strconv.Itoa(start)}
+			list.Content = append(list.Content, adfNode{
+				Type:    "taskItem",
+				Attrs:   map[string]any{"localId": fmt.Sprintf("task-%d-%d", start, n), "state": it.task},
+				Content: parseMarkdownInline(strings.Join(it.lines, " "), nil),
+			})
+			continue
+		}
+		if it.task != "" {
+			// Mixed lists cannot be task lists in ADF; keep the checkbox.
+			box := "[ ] "
+			if it.task == "DONE" {
+				box = "[x] "
+			}
+			it.lines[0] = box + it.lines[0]
+		}
+		list.Content = append(list.Content, adfNode{Type: "listItem", Content: parseMarkdownBlocks(it.lines)})
+	}
+	return list, i
+}
+
+func markdownParagraph(lines []string) adfNode {
+	var content []adfNode
+	for i, line := range lines {
+		if i > 0 {
+			content = append(content, adfNode{Type: "hardBreak"})
+		}
+		line = strings.TrimSuffix(strings.TrimRight(line, " "), `\`)
+		content = append(content, parseMarkdownInline(line, nil)...)
+	}
+	return adfNode{Type: "paragraph", Content: content}
+}
+
+func markdownTable(rows []string) adfNode {
+	table := adfNode{
+		Type:  "table",
+		Attrs: map[string]any{"isNumberColumnEnabled": false, "layout": "default"},
+	}
+	for r, row := range rows {
+		cellType := "tableCell"
+		if r == 0 {
+			cellType = "tableHeader"
+		}
+		tableRow := adfNode{Type: "tableRow"}
+		for _, cell := range splitTableRow(row) {
+			para := adfNode{Type: "paragraph", Content: parseMarkdownInline(cell, nil)}
+			tableRow.Content = append(tableRow.Content, adfNode{Type: cellType, Content: []adfNode{para}})
+		}
+		table.Content = append(table.Content, tableRow)
+	}
+	return table
+}
+
+// splitTableRow splits a table row on unescaped pipes, dropping the optional
+// leading and trailing pipe.
+func splitTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = row[:len(row)-1]
+	}
+	var cells []string
+	var cur strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cur.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(row[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+// parseMarkdownInline converts inline Markdown into text nodes carrying the
+// given marks plus whatever emphasis, code and links appear in s.
+func parseMarkdownInline(s string, marks []adfMark) []adfNode {
+	var nodes []adfNode
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, adfNode{Type: "text", Text: text.String(), Marks: marks})
+			text.Reset()
+		}
+	}
+	emit := func(inner []adfNode) {
+		flush()
+		nodes = append(nodes, inner...)
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		atWordStart := i == 0 || isSpaceOrPunct(s[i-1])
+
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!|~<>@", s[i+1]) >= 0:
+			text.WriteByte(s[i+1])
+			i++
+			continue
+
+		case c == '`':
+			run := 1
+			for i+run < len(s) && s[i+run] == '`' {
+				run++
+			}
+			fence := strings.Repeat("`", run)
+			if end := strings.Index(s[i+run:], fence); end >= 0 {
+				code := s[i+run : i+run+end]
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				// ADF only allows code to combine with link marks.
+				codeMarks := []adfMark{{Type: "code"}}
+				for _, m := range marks {
+					if m.Type == "link" {
+						codeMarks = append(codeMarks, m)
+					}
+				}
+				emit([]adfNode{{Type: "text", Text: code, Marks: codeMarks}})
+				i += run + end + run - 1
+				continue
+			}
+
+		case c == '*' || (c == '_' && atWordStart) || (c == '~' && strings.HasPrefix(s[i:], "~~")):
+			run := 1
+			for i+run < len(s) && s[i+run] == c && run < 3 {
+				run++
+			}
+			if c == '~' {
+				run = 2
+			}
+			delim := s[i : i+run]
+			if end := closingDelimiter(s, i+run, delim); end > i+run {
+				var added []adfMark
+				switch {
+				case c == '~':
+					added = []adfMark{{Type: "strike"}}
+				case run == 1:
+					added = []adfMark{{Type: "em"}}
+				case run == 2:
+					added = []adfMark{{Type: "strong"}}
+				default:
+					added = []adfMark{{Type: "strong"}, {Type: "em"}}
+				}
+				emit(parseMarkdownInline(s[i+run:end], withMarks(marks, added...)))
+				i = end + run - 1
+				continue
+			}
+
+		case c == '[':
+			if closeText := matchingBracket(s, i); closeText > 0 && closeText+1 < len(s) && s[closeText+1] == '(' {
+				if closeURL := strings.IndexByte(s[closeText+2:], ')'); closeURL >= 0 {
+					href := strings.TrimSpace(s[closeText+2 : closeText+2+closeURL])
+					if sp := strings.IndexByte(href, ' '); sp >= 0 {
+						href = href[:sp] // drop an optional "title"
+					}
+					label := s[i+1 : closeText]
+					if label == "" {
+						label = href
+					}
+					emit(parseMarkdownInline(label, withMarks(marks, linkMark(href))))
+					i = closeText + 2 + closeURL
+					continue
+				}
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				href := s[i+1 : i+end]
+				if isURL(href) {
+					emit([]adfNode{{Type: "text", Text: href, Marks: withMarks(marks, linkMark(href))}})
+					i += end
+					continue
+				}
+			}
+
+		case (c == 'h') && atWordStart && (strings.HasPrefix(s[i:], "http://") || strings.HasPrefix(s[i:], "https://")):
+			end := i
+			for end < len(s) && s[end] != ' ' && s[end] != '<' {
+				end++
+			}
+			for end > i && strings.IndexByte(".,;:!?)'\"", s[end-1]) >= 0 {
+				end--
+			}
+			href := s[i:end]
+			if hasLink(marks) {
+				// Already inside [text](url); keep as plain text.
+				text.WriteString(href)
+			} else {
+				emit([]adfNode{{Type: "text", Text: href, Marks: withMarks(marks, linkMark(href))}})
+			}
+			i = end - 1
+			continue
+
+		case c == '@' && atWordStart && !hasLink(marks):
+			if m := mdMention.FindStringSubmatch(s[i:]); m != nil {
+				handle := strings.TrimRight(m[1], ".-")
+				emit([]adfNode{{Type: "mention", Attrs: map[string]any{"query": handle, "text": "@" + handle}}})
+				i += len(handle)
+				continue
+			}
+		}
+		text.WriteByte(c)
+	}
+	flush()
+	return nodes
+}
+
+// closingDelimiter finds the index of delim closing an emphasis span opened
+// just before from, or -1. The closer must not follow whitespace, and an
+// underscore closer must end a word so snake_case survives.
+func closingDelimiter(s string, from int, delim string) int {
+	if from >= len(s) || s[from] == ' ' {
+		return -1
+	}
+	for j := from; j+len(delim) <= len(s); j++ {
+		if s[j] == '`' {
+			// Skip code spans; delimiters inside them are literal.
+			if end := strings.IndexByte(s[j+1:], '`'); end >= 0 {
+				j += end + 1
+				continue
+			}
+		}
+		if !strings.HasPrefix(s[j:], delim) || s[j-1] == ' ' {
+			continue
+		}
+		after := j + len(delim)
+		if after < len(s) && s[after] == delim[0] {
+			// Part of a longer run, e.g. "**" while looking for "*".
+			j = after
+			continue
+		}
+		if delim[0] == '_' && after < len(s) && !isSpaceOrPunct(s[after]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+func matchingBracket(s string, open int) int {
+	depth := 0
+	for j := open; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+func isSpaceOrPunct(c byte) bool {
+	return c == ' ' || strings.IndexByte("([{<\"'*_~,.;:!?/-", c) >= 0
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "mailto") && !strings.ContainsAny(s, " ")
+}
+
+func linkMark(href string) adfMark {
+	return adfMark{Type: "link", Attrs: map[string]any{"href": href}}
+}
+
+func hasLink(marks []adfMark) bool {
+	for _, m := range marks {
+		if m.Type == "link" {
+			return true
+		}
+	}
+	return false
+}
+
+func withMarks(marks []adfMark, added ...adfMark) []adfMark {
+	out := make([]adfMark, 0, len(marks)+len(added))
+	out = append(out, marks...)
+	return append(out, added...)
+}
+
+// rawADF returns s as a raw ADF document when the caller passed one through,
+// so pre-built documents reach Jira untouched.
+func rawADF(s string) (json.RawMessage, bool) {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	var probe struct {
+		Type    string `json:"type"`
+		Version int    `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(trimmed), &probe); err != nil || probe.Type != "doc" || probe.Version == 0 {
+		return nil, false
+	}
+	return json.RawMessage(trimmed), true
+}
+
+// resolveMentions replaces unresolved mention nodes produced by
+// markdownToADF with real mentions for users Jira knows, and with plain
+// text otherwise. Lookups are best effort: a failed search never blocks the
+// write, the handle simply stays text.
+func (p *JiraProvider) resolveMentions(ctx context.Context, nodes []adfNode, cache map[string]*jiraUser) []adfNode {
+	out := nodes[:0]
+	for _, n := range nodes {
+		if n.Type == "mention" {
+			if query, ok := n.Attrs["query"].(string); ok {
+				user, seen := cache[query]
+				if !seen {
+					user = p.findUser(ctx, query)
+					cache[query] = user
+				}
+				if user == nil {
+					out = append(out, adfNode{Type: "text", Text: "@" + query})
+					continue
+				}
+				n.Attrs = map[string]any{"id": user.ID(), "text": "@" + user.DisplayName}
+			}
+		}
+		if len(n.Content) > 0 {
+			n.Content = p.resolveMentions(ctx, n.Content, cache)
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// findUser looks a handle up with the user search API and returns the
+// single best match: an exact email or display-name match, else the only
+// result. Ambiguous or failed searches return nil.
+func (p *JiraProvider) findUser(ctx context.Context, query string) *jiraUser {
+	resp, err := p.do(ctx, apiRequest{
+		method: http.MethodGet,
+		path:   p.apiPath("/user/search?query=" + url.QueryEscape(query) + "&maxResults=10"),
+	})
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var users []struct {
+		jiraUser
+		EmailAddress string `json:"emailAddress"`
+		AccountType  string `json:"accountType"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		return nil
+	}
+
+	var candidates []*jiraUser
+	for i := range users {
+		u := &users[i]
+		if u.AccountType != "" && u.AccountType != "atlassian" {
+			continue // apps and customers cannot be mentioned
+		}
+		if strings.EqualFold(u.EmailAddress, query) || strings.EqualFold(u.DisplayName, query) || strings.EqualFold(u.Name, query) {
+			return &u.jiraUser
+		}
+		candidates = append(candidates, &u.jiraUser)
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	return nil
+}
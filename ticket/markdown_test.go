@@ -0,0 +1,129 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+func TestMarkdownToADFRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain text", in: "Disk usage above 90%", want: "Disk usage above 90%"},
+		{name: "newlines become hard breaks", in: "host: db-1\nvalue: 97%", want: "host: db-1  \nvalue: 97%"},
+		{name: "heading", in: "### Impact", want: "### Impact"},
+		{name: "emphasis", in: "**bold** and *italic* and ~~gone~~ and `code`", want: "**bold** and *italic* and ~~gone~~ and `code`"},
+		{name: "nested emphasis", in: "**very *important***", want: "**very *important***"},
+		{name: "snake case is not emphasis", in: "check disk_usage_pct now", want: "check disk_usage_pct now"},
+		{name: "lone asterisks", in: "2 * 3 * 4", want: "2 * 3 * 4"},
+		{name: "link", in: "see [dashboard](https://grafana.example.com/d/1)", want: "see [dashboard](https://grafana.example.com/d/1)"},
+		{name: "bare url", in: "see https://grafana.example.com/d/1.", want: "see [https://grafana.example.com/d/1](https://grafana.example.com/d/1)."},
+		{name: "bullet list", in: "- one\n- two\n  - nested", want: "- one\n- two\n  - nested"},
+		{name: "ordered list with start", in: "3. three\n4. four", want: "3. three\n4. four"},
+		{name: "task list", in: "- [x] ack\n- [ ] resolve", want: "- [x] ack\n- [ ] resolve"},
+		{name: "code fence", in: "```go\nfmt.Println(\"hi\")\n```", want: "```go\nfmt.Println(\"hi\")\n```"},
+		{name: "table", in: "| Host | Usage |\n|------|------:|\n| db-1 | 97% |", want: "| Host | Usage |\n| --- | --- |\n| db-1 | 97% |"},
+		{name: "blockquote", in: "> escalate", want: "> escalate"},
+		{name: "rule", in: "above\n\n---\n\nbelow", want: "above\n\n---\n\nbelow"},
+		{name: "escaped markers", in: `\*not bold\*`, want: "*not bold*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := markdownToADF(tt.in)
+			if got := renderADFMarkdown(doc); got != tt.want {
+				t.Errorf("round trip = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownToADFStructure(t *testing.T) {
+	doc := markdownToADF("## Summary\n\n```sql\nSELECT 1;\n```\n\n| a | b |\n|---|---|\n| 1 | 2 |")
+	if len(doc.Content) != 3 {
+		t.Fatalf("len(content) = %d, want 3", len(doc.Content))
+	}
+	heading, code, table := doc.Content[0], doc.Content[1], doc.Content[2]
+	if heading.Type != "heading" || heading.Attrs["level"] != 2 {
+		t.Errorf("heading = %+v", heading)
+	}
+	if code.Type != "codeBlock" || code.Attrs["language"] != "sql" || code.Content[0].Text != "SELECT 1;" {
+		t.Errorf("codeBlock = %+v", code)
+	}
+	if table.Type != "table" || len(table.Content) != 2 || table.Content[0].Content[0].Type != "tableHeader" || table.Content[1].Content[0].Type != "tableCell" {
+		t.Errorf("table = %+v", table)
+	}
+}
+
+func TestDescriptionADF(t *testing.T) {
+	var created map[string]any
+	var searches int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/3/user/search":
+			searches++
+			if r.URL.Query().Get("query") == "alice" {
+				json.NewEncoder(w).Encode([]map[string]any{
+					{"accountId": "5b10a2844c20165700ede21g", "displayName": "Alice Smith", "accountType": "atlassian"},
+				})
+				return
+			}
+			json.NewEncoder(w).Encode([]map[string]any{})
+		case r.URL.Path == "/rest/api/3/issue" && r.Method == "POST":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{"id": "10001", "key": "PROJ-1"})
+		case r.URL.Path == "/rest/api/3/issue/PROJ-1":
+			json.NewEncoder(w).Encode(map[string]any{"id": "10001", "key": "PROJ-1", "fields": map[string]any{"summary": "t"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{
+		cfg:    Config{Source: "jira", APIURL: server.URL, ProjectKey: "PROJ", DefaultIssueType: "Task"},
+		client: &http.Client{},
+	}
+	ctx := context.Background()
+
+	t.Run("mentions are resolved to account ids", func(t *testing.T) {
+		_, err := p.Create(ctx, schema.CreateTicketInput{Title: "t", Description: "cc @alice and @nobody, @alice again"})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		raw, _ := json.Marshal(created["fields"].(map[string]any)["description"])
+		var doc adfNode
+		json.Unmarshal(raw, &doc)
+		inline := doc.Content[0].Content
+		if inline[1].Type != "mention" || inline[1].Attrs["id"] != "5b10a2844c20165700ede21g" || inline[1].Attrs["text"] != "@Alice Smith" {
+			t.Errorf("mention = %+v", inline[1])
+		}
+		if got := renderADFMarkdown(doc); got != "cc @Alice Smith and @nobody, @Alice Smith again" {
+			t.Errorf("rendered = %q", got)
+		}
+		if searches != 2 {
+			t.Errorf("user searches = %d, want 2 (cached per handle)", searches)
+		}
+	})
+
+	t.Run("raw ADF is passed through untouched", func(t *testing.T) {
+		in := `{"type":"doc","version":1,"content":[{"type":"panel","attrs":{"panelType":"warning"},"content":[{"type":"paragraph","content":[{"type":"text","text":"careful"}]}]}]}`
+		if _, err := p.Create(ctx, schema.CreateTicketInput{Title: "t", Description: in}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		var want any
+		json.Unmarshal([]byte(in), &want)
+		if got := created["fields"].(map[string]any)["description"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("description = %v, want %v", got, want)
+		}
+	})
+}
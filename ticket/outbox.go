@@ -0,0 +1,652 @@
+package ticket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+// Outbox operations.
+const (
+	outboxCreate     = "create"
+	outboxUpdate     = "update"
+	outboxTransition = "transition"
+)
+
+// outboxReplayInterval is how often the background replayer retries queued
+// writes when nothing else wakes it.
+const outboxReplayInterval = 30 * time.Second
+
+// outboxMaxAttempts is how many times replay sends a write that Jira keeps
+// failing (429, 5xx or a timeout) before giving up on it. Attempts made
+// while Jira is unreachable do not count, so an outage never drops writes.
+const outboxMaxAttempts = 10
+
+// exhaustedSuffix marks a failed entry given up after outboxMaxAttempts.
+const exhaustedSuffix = ".exhausted.json"
+
+// OutboxRootEnv names the environment variable holding the directory under
+// which outboxes are kept. A config's outboxDir only names a subdirectory of
+// it, so configs sent to the plugin cannot choose where the adapter writes.
+const OutboxRootEnv = "OPSORCH_JIRA_OUTBOX_ROOT"
+
+// provisionalPrefix marks the ID returned for a create that is still queued.
+const provisionalPrefix = "outbox-"
+
+// outboxEntry is one pending write. Each entry is its own file, named by
+// its sequence number so a directory listing yields replay order.
+type outboxEntry struct {
+	Seq uint64 `json:"seq"`
+	// Key identifies the operation across retries; it comes from the
+	// caller's metadata["idempotencyKey"] or is generated.
+	Key string `json:"key"`
+	// Site and ProjectKey name the Jira target the write was made for.
+	// Providers sharing the directory replay only their own entries.
+	Site       string                    `json:"site"`
+	ProjectKey string                    `json:"projectKey"`
+	Op         string                    `json:"op"`
+	TicketID   string                    `json:"ticketId,omitempty"`
+	Create     *schema.CreateTicketInput `json:"create,omitempty"`
+	Update     *schema.UpdateTicketInput `json:"update,omitempty"`
+	CreatedAt  time.Time                 `json:"createdAt"`
+	// Attempts counts replays that reached Jira and failed.
+	Attempts  int    `json:"attempts,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// ticket names the issue e writes to: its provisional ID for a create.
+func (e *outboxEntry) ticket() outboxTicket {
+	id := e.TicketID
+	if e.Op == outboxCreate {
+		id = e.provisionalID()
+	}
+	return outboxTicket{site: e.Site, id: id}
+}
+
+// outboxTicket identifies an issue on a Jira site, by key or provisional ID.
+type outboxTicket struct {
+	site, id string
+}
+
+// provisionalID is the ticket ID handed out for a queued create. Later
+// writes may use it; replay swaps in the real key.
+func (e *outboxEntry) provisionalID() string {
+	return provisionalPrefix + e.Key
+}
+
+// OutboxStatus describes the pending writes held on disk.
+type OutboxStatus struct {
+	// Enabled is false when no outbox is configured.
+	Enabled bool `json:"enabled"`
+	Depth   int  `json:"depth"`
+	// OldestAge is how long the oldest pending write has waited.
+	OldestAge time.Duration `json:"-"`
+	// Failed counts writes Jira rejected during replay, kept in the
+	// failed directory for inspection.
+	Failed int `json:"failed"`
+	// Exhausted counts the failed writes that were given up after Jira
+	// kept failing them rather than rejecting them.
+	Exhausted int    `json:"exhausted"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// MarshalJSON reports OldestAge in milliseconds.
+func (s OutboxStatus) MarshalJSON() ([]byte, error) {
+	type status OutboxStatus
+	return json.Marshal(struct {
+		status
+		OldestAge int64 `json:"oldestAgeMs"`
+	}{status(s), s.OldestAge.Milliseconds()})
+}
+
+// outbox is a write-ahead log of Create and Update calls made while Jira
+// was unreachable. A single process owns the directory; within it, every
+// provider configured with the directory shares one outbox.
+type outbox struct {
+	dir string
+
+	// mu guards the queue: the directory, next, queued and resolved. It is
+	// never held across a call to Jira.
+	mu   sync.Mutex
+	next uint64
+	// queued counts the pending entries for each ticket. A write to a
+	// ticket with queued entries queues behind them, so writes to one
+	// ticket keep their order while others go straight to Jira.
+	queued map[outboxTicket]int
+	// resolved maps provisional IDs to the keys of the issues created for
+	// them. It is persisted so the mapping survives restarts.
+	resolved map[string]string
+
+	// replayMu lets one replay run at a time, so no entry is sent twice.
+	replayMu sync.Mutex
+}
+
+// outboxPath resolves a config's outboxDir to a directory under the root
+// named by OutboxRootEnv.
+func outboxPath(name string) (string, error) {
+	root := os.Getenv(OutboxRootEnv)
+	if root == "" {
+		return "", fmt.Errorf("jira outboxDir requires %s to be set", OutboxRootEnv)
+	}
+	if !filepath.IsLocal(name) || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("jira outboxDir must be a directory name under %s, got %q", OutboxRootEnv, name)
+	}
+	return filepath.Join(root, name), nil
+}
+
+// outboxes holds the open outboxes by directory. Providers are rebuilt
+// whenever their config changes, and each must see the same sequence
+// numbers and resolved IDs, so an outbox stays open for the life of the
+// process.
+var outboxes = struct {
+	mu   sync.Mutex
+	open map[string]*outbox
+}{open: map[string]*outbox{}}
+
+// openOutbox returns the outbox for dir, loading it on first use.
+func openOutbox(dir string) (*outbox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("open outbox: %w", err)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open outbox: %w", err)
+	}
+	if abs, err = filepath.EvalSymlinks(abs); err != nil {
+		return nil, fmt.Errorf("open outbox: %w", err)
+	}
+
+	outboxes.mu.Lock()
+	defer outboxes.mu.Unlock()
+	if o, ok := outboxes.open[abs]; ok {
+		return o, nil
+	}
+	o, err := loadOutbox(abs)
+	if err != nil {
+		return nil, err
+	}
+	outboxes.open[abs] = o
+	return o, nil
+}
+
+// loadOutbox reads the queue state from dir.
+func loadOutbox(dir string) (*outbox, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "failed"), 0o700); err != nil {
+		return nil, fmt.Errorf("open outbox: %w", err)
+	}
+	o := &outbox{dir: dir, next: 1, queued: map[outboxTicket]int{}, resolved: map[string]string{}}
+	names, err := o.pending()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		var seq uint64
+		if _, err := fmt.Sscanf(name, "%020d.json", &seq); err == nil && seq >= o.next {
+			o.next = seq + 1
+		}
+		e, err := o.read(name)
+		if err != nil {
+			return nil, err
+		}
+		o.queued[e.ticket()]++
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "resolved.json"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("open outbox: %w", err)
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &o.resolved); err != nil {
+			return nil, fmt.Errorf("open outbox: decode resolved ids: %w", err)
+		}
+	}
+	return o, nil
+}
+
+// pending lists entry file names in replay order.
+func (o *outbox) pending() ([]string, error) {
+	dirents, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read outbox: %w", err)
+	}
+	var names []string
+	for _, d := range dirents {
+		if name := d.Name(); !d.IsDir() && strings.HasSuffix(name, ".json") && name != "resolved.json" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (o *outbox) append(e *outboxEntry) error {
+	e.Seq = o.next
+	if err := writeFileSync(filepath.Join(o.dir, fmt.Sprintf("%020d.json", e.Seq)), e); err != nil {
+		return err
+	}
+	o.next++
+	o.queued[e.ticket()]++
+	return nil
+}
+
+// remove deletes a replayed entry from the queue.
+func (o *outbox) remove(name string, e *outboxEntry) error {
+	if err := os.Remove(filepath.Join(o.dir, name)); err != nil {
+		return fmt.Errorf("remove outbox entry: %w", err)
+	}
+	if t := e.ticket(); o.queued[t] > 1 {
+		o.queued[t]--
+	} else {
+		delete(o.queued, t)
+	}
+	return nil
+}
+
+// waiting reports whether writes to ticket id on site are queued, under
+// its provisional ID or its real key.
+func (o *outbox) waiting(site, id string) bool {
+	id = o.resolveLocked(id)
+	for t := range o.queued {
+		if t.site == site && o.resolveLocked(t.id) == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *outbox) read(name string) (outboxEntry, error) {
+	var e outboxEntry
+	b, err := os.ReadFile(filepath.Join(o.dir, name))
+	if err != nil {
+		return e, fmt.Errorf("read outbox entry: %w", err)
+	}
+	if err := json.Unmarshal(b, &e); err != nil {
+		return e, fmt.Errorf("decode outbox entry %s: %w", name, err)
+	}
+	return e, nil
+}
+
+func (o *outbox) resolve(id string) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.resolveLocked(id)
+}
+
+func (o *outbox) resolveLocked(id string) string {
+	if key, ok := o.resolved[id]; ok {
+		return key
+	}
+	return id
+}
+
+func (o *outbox) status() (OutboxStatus, error) {
+	st := OutboxStatus{Enabled: true}
+	names, err := o.pending()
+	if err != nil {
+		return st, err
+	}
+	st.Depth = len(names)
+	if len(names) > 0 {
+		// A concurrent replay may remove the entry; the depth is then
+		// merely one stale.
+		if oldest, err := o.read(names[0]); err == nil {
+			st.OldestAge = time.Since(oldest.CreatedAt)
+			st.LastError = oldest.LastError
+		}
+	}
+	failed, err := os.ReadDir(filepath.Join(o.dir, "failed"))
+	if err != nil {
+		return st, fmt.Errorf("read outbox: %w", err)
+	}
+	st.Failed = len(failed)
+	for _, d := range failed {
+		if strings.HasSuffix(d.Name(), exhaustedSuffix) {
+			st.Exhausted++
+		}
+	}
+	return st, nil
+}
+
+// writeFileSync replaces path with v encoded as JSON, going through a
+// synced temporary file so a crash never leaves a torn entry behind.
+func writeFileSync(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode outbox entry: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("write outbox: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write outbox: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write outbox: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write outbox: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write outbox: %w", err)
+	}
+	return nil
+}
+
+// unreachable reports whether err means the write never reached a working
+// Jira, so queueing and replaying it cannot apply it twice.
+func unreachable(err error) bool {
+	if errors.Is(err, ErrUnavailable) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryable reports whether a queued write that failed may still succeed
+// later: Jira was unreachable, throttled it, failed on its side or timed
+// out. Replay keeps such a write in place; anything else is a rejection.
+func retryable(err error) bool {
+	if unreachable(err) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// write applies e directly or, when Jira is unreachable or writes to the
+// same ticket are still queued, records it in the outbox and returns a
+// provisional ticket. Queued writes are left to the background replayer.
+// The outbox is locked only to check and extend the queue, so writes to
+// different tickets reach Jira concurrently.
+func (p *JiraProvider) write(ctx context.Context, e outboxEntry) (schema.Ticket, error) {
+	if p.outbox == nil {
+		return p.apply(ctx, e)
+	}
+	if e.Key == "" {
+		e.Key = newIdempotencyKey()
+		if e.Op == outboxCreate {
+			// The issue is tagged with the key from the first attempt, so a
+			// create that reached Jira behind a failed gateway is found on
+			// replay instead of repeated.
+			metadata := map[string]any{"idempotencyKey": e.Key}
+			for k, v := range e.Create.Metadata {
+				metadata[k] = v
+			}
+			in := *e.Create
+			in.Metadata = metadata
+			e.Create = &in
+		}
+	}
+	e.Site, e.ProjectKey = p.cfg.baseURL(), p.cfg.ProjectKey
+
+	p.outbox.mu.Lock()
+	wait := e.Op != outboxCreate && p.outbox.waiting(e.Site, e.TicketID)
+	p.outbox.mu.Unlock()
+	if !wait {
+		t, err := p.apply(ctx, e)
+		if err == nil || !unreachable(err) || t.Key != "" {
+			// A key means the issue was created; queueing it would open
+			// a duplicate.
+			return t, err
+		}
+		e.LastError = err.Error()
+	}
+
+	e.CreatedAt = time.Now().UTC()
+	p.outbox.mu.Lock()
+	err := p.outbox.append(&e)
+	p.outbox.mu.Unlock()
+	if err != nil {
+		return schema.Ticket{}, fmt.Errorf("queue %s: %w", e.Op, err)
+	}
+	p.wakeReplayer()
+	return p.provisionalTicket(e), nil
+}
+
+// apply sends a write to Jira, translating provisional IDs first.
+func (p *JiraProvider) apply(ctx context.Context, e outboxEntry) (schema.Ticket, error) {
+	if e.Op == outboxCreate {
+		return p.create(ctx, *e.Create)
+	}
+	id := e.TicketID
+	if p.outbox != nil {
+		id = p.outbox.resolve(id)
+	}
+	return p.update(ctx, id, *e.Update)
+}
+
+func (p *JiraProvider) provisionalTicket(e outboxEntry) schema.Ticket {
+	t := schema.Ticket{
+		ID:        e.TicketID,
+		Key:       e.TicketID,
+		Status:    "pending",
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.CreatedAt,
+		Metadata: map[string]any{
+			"source":          p.cfg.Source,
+			"provisional":     true,
+			"idempotency_key": e.Key,
+			"outbox_op":       e.Op,
+		},
+	}
+	if e.Op == outboxCreate {
+		t.ID = e.provisionalID()
+		t.Key = t.ID
+		t.Title = e.Create.Title
+		t.Description = e.Create.Description
+	}
+	return t
+}
+
+// replay sends queued writes in order. Writes Jira rejects are moved to the
+// failed directory so they do not block the rest. A write that may yet
+// succeed (see retryable) stays queued: when Jira is down or throttling,
+// replay stops there; when Jira fails on that one issue, later writes to
+// the same ticket wait and replay moves on to other tickets. The error is
+// that of the first write left queued.
+func (p *JiraProvider) replay(ctx context.Context) error {
+	p.outbox.replayMu.Lock()
+	defer p.outbox.replayMu.Unlock()
+
+	blocked := map[outboxTicket]bool{}
+	var first error
+	for {
+		more, err := p.replayNext(ctx, blocked)
+		if err != nil && first == nil {
+			first = err
+		}
+		if !more {
+			return first
+		}
+	}
+}
+
+// replayNext sends the oldest queued write made for this provider's Jira
+// target to a ticket not in blocked. It reports whether replay should go
+// on; a write to a single failing issue adds its ticket to blocked.
+func (p *JiraProvider) replayNext(ctx context.Context, blocked map[outboxTicket]bool) (bool, error) {
+	name, e, err := p.nextEntry(blocked)
+	if err != nil || name == "" {
+		return false, err
+	}
+
+	t, err := p.apply(ctx, e)
+
+	p.outbox.mu.Lock()
+	defer p.outbox.mu.Unlock()
+	path := filepath.Join(p.outbox.dir, name)
+	if err != nil && (e.Op != outboxCreate || t.Key == "") {
+		e.LastError = err.Error()
+		if ctx.Err() == nil && !unreachable(err) {
+			e.Attempts++
+		}
+		failed := filepath.Join(p.outbox.dir, "failed", name)
+		if retryable(err) || ctx.Err() != nil {
+			if e.Attempts < outboxMaxAttempts {
+				if werr := writeFileSync(path, e); werr != nil {
+					return false, werr
+				}
+				if ctx.Err() != nil || !issueFailure(err) {
+					return false, err
+				}
+				blocked[e.ticket()] = true
+				return true, err
+			}
+			failed = strings.TrimSuffix(failed, ".json") + exhaustedSuffix
+		}
+		if werr := writeFileSync(failed, e); werr != nil {
+			return false, werr
+		}
+		return true, p.outbox.remove(name, &e)
+	}
+
+	if e.Op == outboxCreate {
+		p.outbox.resolved[e.provisionalID()] = t.Key
+		if err := writeFileSync(filepath.Join(p.outbox.dir, "resolved.json"), p.outbox.resolved); err != nil {
+			return false, err
+		}
+	}
+	return true, p.outbox.remove(name, &e)
+}
+
+// nextEntry finds the oldest entry replayNext may send.
+func (p *JiraProvider) nextEntry(blocked map[outboxTicket]bool) (string, outboxEntry, error) {
+	p.outbox.mu.Lock()
+	defer p.outbox.mu.Unlock()
+	names, err := p.outbox.pending()
+	if err != nil {
+		return "", outboxEntry{}, err
+	}
+	for _, name := range names {
+		e, err := p.outbox.read(name)
+		if err != nil {
+			return "", outboxEntry{}, err
+		}
+		if p.owns(e) && !blocked[e.ticket()] {
+			return name, e, nil
+		}
+	}
+	return "", outboxEntry{}, nil
+}
+
+// issueFailure reports whether a retryable err concerns only the issue
+// written, as an internal error does, rather than Jira as a whole.
+func issueFailure(err error) bool {
+	var apiErr *APIError
+	return !unreachable(err) && errors.As(err, &apiErr) && apiErr.StatusCode >= 500
+}
+
+// owns reports whether e was queued for this provider's Jira target.
+func (p *JiraProvider) owns(e outboxEntry) bool {
+	return e.Site == p.cfg.baseURL() && e.ProjectKey == p.cfg.ProjectKey
+}
+
+// ReplayOutbox sends the writes queued for this provider's Jira target and
+// reports what is left in the directory. It is
+// a no-op when no outbox is configured.
+func (p *JiraProvider) ReplayOutbox(ctx context.Context) (OutboxStatus, error) {
+	if p.outbox == nil {
+		return OutboxStatus{}, nil
+	}
+	err := p.replay(ctx)
+	st, serr := p.outbox.status()
+	if err == nil {
+		err = serr
+	}
+	return st, err
+}
+
+// startReplayer drains the outbox in the background: every
+// outboxReplayInterval, when a write is queued and when the circuit breaker
+// closes. Close stops it.
+func (p *JiraProvider) startReplayer() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopReplayer = cancel
+	p.replayWake = make(chan struct{}, 1)
+	go func() {
+		ticker := time.NewTicker(outboxReplayInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-p.replayWake:
+			}
+			// A write that still cannot get through stays queued for
+			// the next round.
+			_ = p.replay(ctx)
+		}
+	}()
+}
+
+// wakeReplayer asks the background replayer to run now. It never blocks.
+func (p *JiraProvider) wakeReplayer() {
+	if p.replayWake == nil {
+		return
+	}
+	select {
+	case p.replayWake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the background replayer. Queued writes stay on disk for the
+// next provider using the directory.
+func (p *JiraProvider) Close() error {
+	if p.stopReplayer != nil {
+		p.stopReplayer()
+	}
+	return nil
+}
+
+// Outbox reports the pending writes without contacting Jira or waiting for
+// a replay in progress.
+func (p *JiraProvider) Outbox() (OutboxStatus, error) {
+	if p.outbox == nil {
+		return OutboxStatus{}, nil
+	}
+	return p.outbox.status()
+}
+
+// idempotencyKey returns the caller-supplied metadata["idempotencyKey"].
+func idempotencyKey(metadata map[string]any) string {
+	if v, ok := metadata["idempotencyKey"].(string); ok {
+		return strings.TrimSpace(v)
+	}
+	return ""
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,540 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+func TestOutbox(t *testing.T) {
+	var down atomic.Bool
+	var mu sync.Mutex
+	var writes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch {
+		case r.URL.Path == "/rest/api/3/search/jql":
+			w.Write([]byte(`{"issues":[],"isLast":true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/3/issue":
+			var payload struct {
+				Fields map[string]any `json:"fields"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			if payload.Fields["summary"] == "rejected" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":{"summary":"bad"}}`))
+				return
+			}
+			mu.Lock()
+			writes = append(writes, "create")
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"10001","key":"PROJ-1"}`))
+		case r.Method == http.MethodPut:
+			mu.Lock()
+			writes = append(writes, "update "+strings.TrimPrefix(r.URL.Path, "/rest/api/3/issue/"))
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`{"id":"10001","key":"PROJ-1","fields":{"summary":"Disk full","status":{"name":"To Do"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	ob, err := openOutbox(dir)
+	if err != nil {
+		t.Fatalf("openOutbox() error = %v", err)
+	}
+	p := &JiraProvider{
+		cfg:    Config{APIURL: server.URL, ProjectKey: "PROJ", Source: "jira", IdempotencyProperty: "opsorch"},
+		client: &http.Client{},
+		outbox: ob,
+	}
+	ctx := context.Background()
+
+	down.Store(true)
+	created, err := p.Create(ctx, schema.CreateTicketInput{Title: "Disk full", Metadata: map[string]any{"idempotencyKey": "inc-42"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if created.ID != "outbox-inc-42" || created.Metadata["provisional"] != true {
+		t.Errorf("Create() = %+v, want a provisional ticket", created)
+	}
+	// The update waits behind its create even once Jira is back.
+	down.Store(false)
+	title := "Disk full on db-1"
+	if got, err := p.Update(ctx, created.ID, schema.UpdateTicketInput{Title: &title}); err != nil || got.Metadata["provisional"] != true {
+		t.Fatalf("Update() = %+v, %v, want it queued", got, err)
+	}
+
+	// A restarted provider sees the same queue.
+	ob, err = loadOutbox(dir)
+	if err != nil {
+		t.Fatalf("reopen outbox error = %v", err)
+	}
+	p.outbox = ob
+	st, err := p.Outbox()
+	if err != nil || !st.Enabled || st.Depth != 2 || st.OldestAge <= 0 {
+		t.Fatalf("Outbox() = %+v, %v, want 2 pending writes", st, err)
+	}
+
+	st, err = p.ReplayOutbox(ctx)
+	if err != nil {
+		t.Fatalf("ReplayOutbox() error = %v", err)
+	}
+	if st.Depth != 0 {
+		t.Errorf("depth after replay = %v, want 0", st.Depth)
+	}
+	if want := []string{"create", "update PROJ-1"}; strings.Join(writes, ",") != strings.Join(want, ",") {
+		t.Errorf("writes = %v, want %v", writes, want)
+	}
+
+	// Writes Jira rejects are set aside instead of blocking the queue.
+	down.Store(true)
+	if _, err := p.Create(ctx, schema.CreateTicketInput{Title: "rejected"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	down.Store(false)
+	// A write to another ticket does not wait behind it.
+	if got, err := p.Update(ctx, "PROJ-1", schema.UpdateTicketInput{Title: &title}); err != nil || got.Metadata["provisional"] != nil {
+		t.Fatalf("Update() = %+v, %v, want it sent at once", got, err)
+	}
+	if st, err := p.ReplayOutbox(ctx); err != nil || st.Depth != 0 || st.Failed != 1 {
+		t.Errorf("ReplayOutbox() = %+v, %v, want the rejected create moved aside", st, err)
+	}
+}
+
+func TestReplaySkipsFailingIssue(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.Write([]byte(`{"id":"10001","key":"OK-1","fields":{"summary":"Disk full","status":{"name":"To Do"}}}`))
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/BAD-1") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		mu.Lock()
+		sent = append(sent, strings.TrimPrefix(r.URL.Path, "/rest/api/3/issue/"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	ob, err := loadOutbox(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadOutbox() error = %v", err)
+	}
+	title := "Disk full"
+	ob.mu.Lock()
+	for _, id := range []string{"BAD-1", "OK-1", "BAD-1"} {
+		ob.append(&outboxEntry{Key: id, Site: server.URL, ProjectKey: "PROJ", Op: outboxUpdate, TicketID: id, Update: &schema.UpdateTicketInput{Title: &title}, CreatedAt: time.Now()})
+	}
+	ob.mu.Unlock()
+	p := &JiraProvider{cfg: Config{APIURL: server.URL, ProjectKey: "PROJ", Source: "jira"}, client: &http.Client{}, outbox: ob}
+
+	st, err := p.ReplayOutbox(context.Background())
+	if err == nil || st.Depth != 2 {
+		t.Errorf("ReplayOutbox() = %+v, %v, want both BAD-1 writes kept and the error", st, err)
+	}
+	if strings.Join(sent, ",") != "OK-1" {
+		t.Errorf("sent %v, want OK-1 despite BAD-1 failing", sent)
+	}
+}
+
+func TestReplayGivesUpOnPersistentFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/BAD-1"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Write([]byte(`{"id":"10001","key":"OK-1","fields":{"summary":"Disk full","status":{"name":"To Do"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	ob, err := loadOutbox(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadOutbox() error = %v", err)
+	}
+	title := "Disk full"
+	ob.mu.Lock()
+	ob.append(&outboxEntry{Key: "k", Site: server.URL, ProjectKey: "PROJ", Op: outboxUpdate, TicketID: "BAD-1", Update: &schema.UpdateTicketInput{Title: &title}, CreatedAt: time.Now()})
+	ob.mu.Unlock()
+	p := &JiraProvider{cfg: Config{APIURL: server.URL, ProjectKey: "PROJ", Source: "jira"}, client: &http.Client{}, outbox: ob}
+
+	for i := 1; i < outboxMaxAttempts; i++ {
+		if st, _ := p.ReplayOutbox(context.Background()); st.Depth != 1 {
+			t.Fatalf("ReplayOutbox() after %d attempts = %+v, want the write still queued", i, st)
+		}
+	}
+	st, err := p.ReplayOutbox(context.Background())
+	if err != nil || st.Depth != 0 || st.Failed != 1 || st.Exhausted != 1 {
+		t.Errorf("ReplayOutbox() = %+v, %v, want the write given up after %d attempts", st, err, outboxMaxAttempts)
+	}
+
+	if got, err := p.Update(context.Background(), "BAD-1", schema.UpdateTicketInput{Title: &title}); err == nil || got.Metadata["provisional"] != nil {
+		t.Errorf("Update() = %+v, %v, want it sent and failing rather than queued", got, err)
+	}
+}
+
+func TestOutboxWritesRunConcurrently(t *testing.T) {
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			// Each update is held until both are in flight.
+			arrived.Done()
+			arrived.Wait()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"id":"10001","key":"PROJ-1","fields":{"summary":"Disk full","status":{"name":"To Do"}}}`))
+	}))
+	defer server.Close()
+
+	ob, err := loadOutbox(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadOutbox() error = %v", err)
+	}
+	p := &JiraProvider{
+		cfg:    Config{APIURL: server.URL, ProjectKey: "PROJ", Source: "jira"},
+		client: &http.Client{Timeout: 5 * time.Second},
+		outbox: ob,
+	}
+
+	title := "Disk full"
+	errs := make(chan error, 2)
+	for _, id := range []string{"PROJ-1", "PROJ-2"} {
+		go func(id string) {
+			_, err := p.Update(context.Background(), id, schema.UpdateTicketInput{Title: &title})
+			errs <- err
+		}(id)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Update() error = %v, want updates to run side by side", err)
+		}
+	}
+}
+
+func TestOutboxBackgroundReplay(t *testing.T) {
+	var down atomic.Bool
+	var creates atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case down.Load():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/rest/api/3/search/jql":
+			w.Write([]byte(`{"issues":[],"isLast":true}`))
+		case r.Method == http.MethodPost:
+			creates.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"10001","key":"PROJ-1"}`))
+		default:
+			w.Write([]byte(`{"id":"10001","key":"PROJ-1","fields":{"summary":"Disk full","status":{"name":"To Do"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	ob, err := loadOutbox(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadOutbox() error = %v", err)
+	}
+	p := &JiraProvider{
+		cfg:    Config{APIURL: server.URL, ProjectKey: "PROJ", Source: "jira", IdempotencyProperty: "opsorch"},
+		client: &http.Client{},
+		outbox: ob,
+	}
+	p.startReplayer()
+	defer p.Close()
+
+	down.Store(true)
+	if _, err := p.Create(context.Background(), schema.CreateTicketInput{Title: "Disk full"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	down.Store(false)
+	p.wakeReplayer()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st, _ := p.Outbox()
+		if st.Depth == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Outbox() = %+v, want the replayer to drain it", st)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := creates.Load(); n != 1 {
+		t.Errorf("creates = %d, want 1", n)
+	}
+}
+
+func TestOutboxCreateBehindGatewayTimeout(t *testing.T) {
+	var mu sync.Mutex
+	var posts int
+	var tagged string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/rest/api/3/search/jql":
+			var payload struct {
+				JQL string `json:"jql"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			if tagged != "" && strings.Contains(payload.JQL, tagged) {
+				w.Write([]byte(`{"issues":[{"id":"10001","key":"PROJ-1","fields":{"summary":"Disk full","status":{"name":"To Do"}}}],"isLast":true}`))
+				return
+			}
+			w.Write([]byte(`{"issues":[],"isLast":true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/3/issue":
+			// Jira creates the issue, but the gateway times out.
+			var payload struct {
+				Properties []struct {
+					Value externalKeys `json:"value"`
+				} `json:"properties"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			posts++
+			if len(payload.Properties) > 0 && len(payload.Properties[0].Value.IDs) > 0 {
+				tagged = payload.Properties[0].Value.IDs[0]
+			}
+			w.WriteHeader(http.StatusGatewayTimeout)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ob, err := loadOutbox(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadOutbox() error = %v", err)
+	}
+	p := &JiraProvider{
+		cfg:    Config{APIURL: server.URL, ProjectKey: "PROJ", Source: "jira", IdempotencyProperty: "opsorch"},
+		client: &http.Client{},
+		outbox: ob,
+	}
+
+	created, err := p.Create(context.Background(), schema.CreateTicketInput{Title: "Disk full"})
+	if err != nil || created.Metadata["provisional"] != true {
+		t.Fatalf("Create() = %+v, %v, want it queued", created, err)
+	}
+	if tagged == "" {
+		t.Fatal("first attempt was sent without an idempotency key")
+	}
+	if st, err := p.ReplayOutbox(context.Background()); err != nil || st.Depth != 0 {
+		t.Fatalf("ReplayOutbox() = %+v, %v", st, err)
+	}
+	if posts != 1 {
+		t.Errorf("sent %d creates, want the replay to find the first", posts)
+	}
+}
+
+func TestOutboxReplaysOnlyOwnTarget(t *testing.T) {
+	var down atomic.Bool
+	var mu sync.Mutex
+	var projects []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case down.Load():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/rest/api/3/search/jql":
+			w.Write([]byte(`{"issues":[],"isLast":true}`))
+		case r.Method == http.MethodPost:
+			var payload struct {
+				Fields struct {
+					Project struct {
+						Key string `json:"key"`
+					} `json:"project"`
+				} `json:"fields"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			mu.Lock()
+			projects = append(projects, payload.Fields.Project.Key)
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"10001","key":"AAA-1"}`))
+		default:
+			w.Write([]byte(`{"id":"10001","key":"AAA-1","fields":{"summary":"Disk full","status":{"name":"To Do"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	ob, err := loadOutbox(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadOutbox() error = %v", err)
+	}
+	provider := func(project string) *JiraProvider {
+		return &JiraProvider{
+			cfg:    Config{APIURL: server.URL, ProjectKey: project, Source: "jira", IdempotencyProperty: "opsorch"},
+			client: &http.Client{},
+			outbox: ob,
+		}
+	}
+	a, b := provider("AAA"), provider("BBB")
+
+	down.Store(true)
+	if _, err := a.Create(context.Background(), schema.CreateTicketInput{Title: "Disk full"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	down.Store(false)
+
+	if st, err := b.ReplayOutbox(context.Background()); err != nil || st.Depth != 1 {
+		t.Errorf("ReplayOutbox() for another project = %+v, %v, want the entry left alone", st, err)
+	}
+	if st, err := a.ReplayOutbox(context.Background()); err != nil || st.Depth != 0 {
+		t.Errorf("ReplayOutbox() = %+v, %v, want the entry replayed", st, err)
+	}
+	if strings.Join(projects, ",") != "AAA" {
+		t.Errorf("created in projects %v, want only AAA", projects)
+	}
+}
+
+func TestNewOutboxDir(t *testing.T) {
+	cfg := func(dir string) map[string]any {
+		return map[string]any{"apiToken": "pat", "deployment": "datacenter", "apiURL": "https://jira.example.com", "projectKey": "PROJ", "outboxDir": dir}
+	}
+
+	t.Setenv(OutboxRootEnv, "")
+	if _, err := New(cfg("ops")); err == nil || !strings.Contains(err.Error(), OutboxRootEnv) {
+		t.Errorf("New() without an outbox root error = %v, want it to name %s", err, OutboxRootEnv)
+	}
+
+	root := t.TempDir()
+	t.Setenv(OutboxRootEnv, root)
+	for _, dir := range []string{"/var/tmp/outbox", "../outbox", "a/b", `a\b`, ".."} {
+		if _, err := New(cfg(dir)); err == nil {
+			t.Errorf("New() with outboxDir %q error = nil, want it rejected", dir)
+		}
+	}
+
+	prov, err := New(cfg("ops"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer prov.(*JiraProvider).Close()
+	if st, err := prov.(*JiraProvider).Outbox(); err != nil || !st.Enabled {
+		t.Errorf("Outbox() = %+v, %v, want it enabled", st, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "ops", "failed")); err != nil {
+		t.Errorf("outbox not created under the root: %v", err)
+	}
+}
+
+func TestOpenOutboxShared(t *testing.T) {
+	dir := t.TempDir()
+	a, err := openOutbox(dir)
+	if err != nil {
+		t.Fatalf("openOutbox() error = %v", err)
+	}
+	b, err := openOutbox(filepath.Join(dir, "failed", ".."))
+	if err != nil {
+		t.Fatalf("openOutbox() error = %v", err)
+	}
+	if a != b {
+		t.Fatalf("openOutbox() returned two outboxes for one directory")
+	}
+
+	a.mu.Lock()
+	err = a.append(&outboxEntry{Op: outboxUpdate})
+	a.mu.Unlock()
+	if err != nil {
+		t.Fatalf("append() error = %v", err)
+	}
+	if b.next != 2 {
+		t.Errorf("next = %d after one append, want 2", b.next)
+	}
+}
+
+func TestReplayKeepsRetryableFailures(t *testing.T) {
+	var status atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	ob, err := loadOutbox(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadOutbox() error = %v", err)
+	}
+	title := "Disk full on db-1"
+	ob.mu.Lock()
+	err = ob.append(&outboxEntry{Key: "k", Site: server.URL, ProjectKey: "PROJ", Op: outboxUpdate, TicketID: "PROJ-1", Update: &schema.UpdateTicketInput{Title: &title}, CreatedAt: time.Now()})
+	ob.mu.Unlock()
+	if err != nil {
+		t.Fatalf("append() error = %v", err)
+	}
+	p := &JiraProvider{
+		cfg:    Config{APIURL: server.URL, ProjectKey: "PROJ", Source: "jira"},
+		client: &http.Client{},
+		outbox: ob,
+	}
+
+	for _, code := range []int{http.StatusTooManyRequests, http.StatusInternalServerError} {
+		status.Store(int32(code))
+		st, err := p.ReplayOutbox(context.Background())
+		if err == nil {
+			t.Errorf("ReplayOutbox() on %d error = nil, want the failure", code)
+		}
+		if st.Depth != 1 || st.Failed != 0 {
+			t.Errorf("ReplayOutbox() on %d = %+v, want the write kept queued", code, st)
+		}
+	}
+
+	status.Store(http.StatusBadRequest)
+	if st, err := p.ReplayOutbox(context.Background()); st.Depth != 0 || st.Failed != 1 {
+		t.Errorf("ReplayOutbox() on 400 = %+v, %v, want the write moved aside", st, err)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		// unreachable: queue a new write; retryable: keep a queued one.
+		unreachable, retryable bool
+	}{
+		{name: "breaker open", err: &UnavailableError{}, unreachable: true, retryable: true},
+		{name: "service unavailable", err: &APIError{StatusCode: http.StatusServiceUnavailable}, unreachable: true, retryable: true},
+		{name: "internal error", err: &APIError{StatusCode: http.StatusInternalServerError}, retryable: true},
+		{name: "rate limited", err: &APIError{StatusCode: http.StatusTooManyRequests}, retryable: true},
+		{name: "timeout", err: fmt.Errorf("update: %w", context.DeadlineExceeded), retryable: true},
+		{name: "validation", err: &APIError{StatusCode: http.StatusBadRequest}},
+		{name: "not found", err: &APIError{StatusCode: http.StatusNotFound}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unreachable(tt.err); got != tt.unreachable {
+				t.Errorf("unreachable() = %v, want %v", got, tt.unreachable)
+			}
+			if got := retryable(tt.err); got != tt.retryable {
+				t.Errorf("retryable() = %v, want %v", got, tt.retryable)
+			}
+		})
+	}
+}
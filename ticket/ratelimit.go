@@ -0,0 +1,193 @@
+package ticket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limit defaults applied by parseConfig. They stay well under Jira
+// Cloud's per-user quota so a burst of automation leaves headroom for people
+// using the same tenant.
+const (
+	defaultReadRateLimit     = 10
+	defaultReadBurst         = 20
+	defaultWriteRateLimit    = 5
+	defaultWriteBurst        = 10
+	defaultMaxInFlightReads  = 10
+	defaultMaxInFlightWrites = 5
+)
+
+// Adaptive pacing: a throttled or near-limit response halves a budget's rate,
+// down to minRateFactor of the configured rate, and every normal response
+// wins back rateRecoveryFactor of it.
+const (
+	minRateFactor      = 0.1
+	rateRecoveryFactor = 0.05
+)
+
+// rateLimiter paces requests to Jira. Reads and writes draw from separate
+// token buckets and in-flight caps, so a storm of searches cannot starve
+// ticket creation. Jira's throttling applies to the whole account, though, so
+// a Retry-After pauses both.
+type rateLimiter struct {
+	read, write *budget
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// budget is a token bucket plus an in-flight cap for one class of requests.
+type budget struct {
+	// slots holds one entry per request in flight; nil means no cap.
+	slots chan struct{}
+
+	mu sync.Mutex
+	// rate is the configured refill per second and current the rate adapted
+	// to Jira's feedback. A zero rate disables pacing.
+	rate, current float64
+	burst, tokens float64
+	last          time.Time
+}
+
+func newRateLimiter(cfg Config) *rateLimiter {
+	return &rateLimiter{
+		read:  newBudget(cfg.ReadRateLimit, cfg.ReadBurst, cfg.MaxInFlightReads),
+		write: newBudget(cfg.WriteRateLimit, cfg.WriteBurst, cfg.MaxInFlightWrites),
+	}
+}
+
+func newBudget(rate float64, burst, maxInFlight int) *budget {
+	if burst < 1 {
+		burst = 1
+	}
+	b := &budget{rate: rate, current: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+	if maxInFlight > 0 {
+		b.slots = make(chan struct{}, maxInFlight)
+	}
+	return b
+}
+
+// acquire waits until a request of the given class may be sent and returns
+// the function that marks it finished. A pause longer than maxWait fails
+// immediately with a rate-limit error instead of tying up the caller.
+func (l *rateLimiter) acquire(ctx context.Context, read bool, maxWait time.Duration) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	b := l.write
+	if read {
+		b = l.read
+	}
+
+	l.mu.Lock()
+	pause := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+	if pause > maxWait {
+		return nil, &APIError{
+			StatusCode: http.StatusTooManyRequests,
+			Messages:   []string{fmt.Sprintf("jira asked clients to back off for %s", pause.Round(time.Second))},
+			RetryAfter: pause,
+		}
+	}
+	if err := sleepContext(ctx, pause); err != nil {
+		return nil, err
+	}
+
+	if wait := b.reserve(time.Now()); wait > 0 {
+		if err := sleepContext(ctx, wait); err != nil {
+			b.refund()
+			return nil, err
+		}
+	}
+
+	if b.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return func() { <-b.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// observe adapts the pacing to Jira's rate limit headers on resp.
+func (l *rateLimiter) observe(read bool, resp *http.Response, now time.Time) {
+	if l == nil {
+		return
+	}
+	b := l.write
+	if read {
+		b = l.read
+	}
+
+	throttled := resp.StatusCode == http.StatusTooManyRequests
+	exhausted := strings.TrimSpace(resp.Header.Get("X-RateLimit-Remaining")) == "0"
+	if throttled || exhausted {
+		if wait, ok := retryAfter(resp.Header, now); ok {
+			l.pause(now.Add(wait))
+		}
+	}
+
+	nearLimit, _ := strconv.ParseBool(resp.Header.Get("X-RateLimit-NearLimit"))
+	if throttled || exhausted || nearLimit {
+		b.slowDown()
+	} else if resp.StatusCode < http.StatusInternalServerError {
+		b.recover()
+	}
+}
+
+func (l *rateLimiter) pause(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it. The
+// bucket may go into debt, which queues callers in arrival order.
+func (b *budget) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate <= 0 {
+		return 0
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.current
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.current * float64(time.Second))
+}
+
+func (b *budget) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate > 0 {
+		b.tokens++
+	}
+}
+
+func (b *budget) slowDown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current = max(b.current/2, b.rate*minRateFactor)
+}
+
+func (b *budget) recover() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current = min(b.current+b.rate*rateRecoveryFactor, b.rate)
+}
@@ -0,0 +1,122 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBudgets(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reads do not drain the write budget", func(t *testing.T) {
+		l := newRateLimiter(Config{ReadRateLimit: 0.001, ReadBurst: 1, WriteRateLimit: 0.001, WriteBurst: 1})
+		release, err := l.acquire(ctx, true, time.Second)
+		if err != nil {
+			t.Fatalf("acquire(read) error = %v", err)
+		}
+		release()
+
+		if wait := l.read.reserve(time.Now()); wait <= 0 {
+			t.Errorf("read bucket wait = %v, want a positive wait once the burst is spent", wait)
+		}
+		if wait := l.write.reserve(time.Now()); wait != 0 {
+			t.Errorf("write bucket wait = %v, want 0", wait)
+		}
+	})
+
+	t.Run("caps requests in flight", func(t *testing.T) {
+		l := newRateLimiter(Config{MaxInFlightWrites: 1})
+		release, err := l.acquire(ctx, false, time.Second)
+		if err != nil {
+			t.Fatalf("acquire() error = %v", err)
+		}
+
+		short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := l.acquire(short, false, time.Second); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("second acquire() error = %v, want deadline exceeded", err)
+		}
+		if _, err := l.acquire(ctx, true, time.Second); err != nil {
+			t.Errorf("read acquire() error = %v, want reads unaffected", err)
+		}
+
+		release()
+		if _, err := l.acquire(ctx, false, time.Second); err != nil {
+			t.Errorf("acquire() after release error = %v", err)
+		}
+	})
+
+	t.Run("fails fast when paused longer than the caller waits", func(t *testing.T) {
+		l := newRateLimiter(Config{})
+		l.pause(time.Now().Add(time.Minute))
+		_, err := l.acquire(ctx, false, time.Second)
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("acquire() error = %v, want ErrRateLimited", err)
+		}
+	})
+}
+
+func TestRateLimiterObserve(t *testing.T) {
+	now := time.Now()
+	l := newRateLimiter(Config{ReadRateLimit: 10, ReadBurst: 1, WriteRateLimit: 10, WriteBurst: 1})
+
+	throttled := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"5"}}}
+	l.observe(true, throttled, now)
+	if l.read.current != 5 {
+		t.Errorf("read rate = %v after a 429, want 5", l.read.current)
+	}
+	if l.write.current != 10 {
+		t.Errorf("write rate = %v, want 10", l.write.current)
+	}
+	if got := l.pausedUntil.Sub(now); got != 5*time.Second {
+		t.Errorf("pause = %v, want 5s", got)
+	}
+
+	nearLimit := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Ratelimit-Nearlimit": {"true"}}}
+	for i := 0; i < 10; i++ {
+		l.observe(true, nearLimit, now)
+	}
+	if l.read.current != 1 {
+		t.Errorf("read rate = %v, want the floor of 1", l.read.current)
+	}
+
+	ok := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	for i := 0; i < 30; i++ {
+		l.observe(true, ok, now)
+	}
+	if l.read.current != 10 {
+		t.Errorf("read rate = %v after recovery, want 10", l.read.current)
+	}
+}
+
+func TestDoReleasesInFlightSlot(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{
+		cfg:     Config{APIURL: server.URL},
+		client:  &http.Client{},
+		limiter: newRateLimiter(Config{MaxInFlightReads: 1}),
+	}
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		resp, err := p.do(ctx, apiRequest{method: http.MethodGet, path: "/rest/api/3/issue/PROJ-1"})
+		cancel()
+		if err != nil {
+			t.Fatalf("do() call %d error = %v", i+1, err)
+		}
+		resp.Body.Close()
+	}
+	if calls != 3 {
+		t.Errorf("calls = %v, want 3", calls)
+	}
+}
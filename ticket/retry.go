@@ -0,0 +1,100 @@
+package ticket
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retry defaults applied by parseConfig when the config does not override them.
+const (
+	defaultMaxRetries     = 3
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+// shouldRetry decides whether a response status is worth another attempt.
+// A 429 means Jira rejected the request outright, so it is always safe to
+// repeat. Server errors are ambiguous and only retried for safe requests.
+func shouldRetry(status int, safe bool) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return safe
+	}
+	return false
+}
+
+// backoff returns the jittered exponential delay before retry number attempt+1.
+func (p *JiraProvider) backoff(attempt int) time.Duration {
+	base := p.cfg.RetryBaseDelay
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+	maxDelay := p.retryMaxDelay()
+
+	delay := base
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	// Equal jitter: wait at least half the delay so retries still spread out
+	// while never hammering Jira immediately.
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter extracts the server-requested wait from Retry-After (seconds or
+// HTTP date) or Jira's X-RateLimit-Reset (ISO 8601 timestamp).
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	if v := strings.TrimSpace(h.Get("Retry-After")); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second, true
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return nonNegative(at.Sub(now)), true
+		}
+	}
+	if v := strings.TrimSpace(h.Get("X-RateLimit-Reset")); v != "" {
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00"} {
+			if at, err := time.Parse(layout, v); err == nil {
+				return nonNegative(at.Sub(now)), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (p *JiraProvider) retryMaxDelay() time.Duration {
+	if p.cfg.RetryMaxDelay <= 0 {
+		return defaultRetryMaxDelay
+	}
+	return p.cfg.RetryMaxDelay
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,161 @@
+package ticket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoRetries(t *testing.T) {
+	newProvider := func(url string) *JiraProvider {
+		return &JiraProvider{
+			cfg: Config{
+				APIURL:         url,
+				MaxRetries:     3,
+				RetryBaseDelay: time.Millisecond,
+				RetryMaxDelay:  50 * time.Millisecond,
+			},
+			client: &http.Client{},
+		}
+	}
+	ctx := context.Background()
+
+	t.Run("retries 429 honouring Retry-After", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer server.Close()
+
+		resp, err := newProvider(server.URL).do(ctx, apiRequest{method: http.MethodPost, path: "/rest/api/3/issue", body: map[string]any{}})
+		if err != nil {
+			t.Fatalf("do() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("StatusCode = %v, want 201", resp.StatusCode)
+		}
+		if calls != 2 {
+			t.Errorf("calls = %v, want 2", calls)
+		}
+	})
+
+	t.Run("retries 5xx for idempotent requests", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		resp, err := newProvider(server.URL).do(ctx, apiRequest{method: http.MethodGet, path: "/rest/api/3/issue/PROJ-1"})
+		if err != nil {
+			t.Fatalf("do() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || calls != 3 {
+			t.Errorf("StatusCode = %v after %v calls, want 200 after 3", resp.StatusCode, calls)
+		}
+	})
+
+	t.Run("does not retry 5xx for non-idempotent requests", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		resp, err := newProvider(server.URL).do(ctx, apiRequest{method: http.MethodPost, path: "/rest/api/3/issue", body: map[string]any{}})
+		if err != nil {
+			t.Fatalf("do() error = %v", err)
+		}
+		resp.Body.Close()
+		if calls != 1 {
+			t.Errorf("calls = %v, want 1", calls)
+		}
+	})
+
+	t.Run("gives up when Retry-After exceeds the max delay", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		resp, err := newProvider(server.URL).do(ctx, apiRequest{method: http.MethodGet, path: "/"})
+		if err != nil {
+			t.Fatalf("do() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests || calls != 1 {
+			t.Errorf("StatusCode = %v after %v calls, want 429 after 1", resp.StatusCode, calls)
+		}
+	})
+
+	t.Run("stops after the retry budget", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		resp, err := newProvider(server.URL).do(ctx, apiRequest{method: http.MethodGet, path: "/"})
+		if err != nil {
+			t.Fatalf("do() error = %v", err)
+		}
+		resp.Body.Close()
+		if calls != 4 {
+			t.Errorf("calls = %v, want 4", calls)
+		}
+	})
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 11, 21, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+		ok     bool
+	}{
+		{name: "seconds", header: http.Header{"Retry-After": {"7"}}, want: 7 * time.Second, ok: true},
+		{name: "http date", header: http.Header{"Retry-After": {"Fri, 21 Nov 2025 10:00:05 GMT"}}, want: 5 * time.Second, ok: true},
+		{name: "rate limit reset", header: http.Header{"X-Ratelimit-Reset": {"2025-11-21T10:00:30Z"}}, want: 30 * time.Second, ok: true},
+		{name: "reset in the past", header: http.Header{"X-Ratelimit-Reset": {"2025-11-21T09:00:00Z"}}, want: 0, ok: true},
+		{name: "no header", header: http.Header{}, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.header, now)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("retryAfter() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := &JiraProvider{cfg: Config{RetryBaseDelay: 100 * time.Millisecond, RetryMaxDelay: time.Second}}
+	for attempt := 0; attempt < 6; attempt++ {
+		d := p.backoff(attempt)
+		if d <= 0 || d > time.Second {
+			t.Errorf("backoff(%d) = %v, want within (0, 1s]", attempt, d)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package ticket
+
+import (
+	_ "embed"
+	"encoding/json"
+)
+
+//go:embed config.schema.json
+var configSchema []byte
+
+// ConfigSchema returns a JSON Schema describing the config map accepted by
+// New, so hosts can validate and render adapter settings.
+func ConfigSchema() json.RawMessage {
+	return append(json.RawMessage(nil), configSchema...)
+}
@@ -0,0 +1,38 @@
+package ticket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestConfigSchema(t *testing.T) {
+	var schema struct {
+		Required   []string                  `json:"required"`
+		Properties map[string]map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal(ConfigSchema(), &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	// Every key parseConfig understands must be documented.
+	keys := []string{
+		"source", "apiToken", "email", "apiURL", "projectKey", "defaultIssueType",
+		"deployment", "auth", "cloudId", "gatewayURL",
+		"oauthClientId", "oauthClientSecret", "oauthTokenURL", "oauthAuthorizationCode",
+		"oauthRedirectURI", "oauthRefreshToken", "oauthAccessToken", "oauthTokenExpiry",
+		"maxRetries", "retryBaseDelay", "retryMaxDelay",
+		"readRateLimit", "readBurst", "writeRateLimit", "writeBurst", "maxInFlightReads", "maxInFlightWrites",
+		"requestTimeout", "breakerThreshold", "breakerCooldown", "idempotencyProperty",
+		"dedupMode", "dedupFields", "dedupWindow", "dedupOpenStatuses", "outboxDir",
+		"statusMapping", "priorityMapping",
+		"maxAttachmentSize", "attachmentMimeDetection", "allowedAttachmentTypes",
+	}
+	for _, key := range keys {
+		if _, ok := schema.Properties[key]; !ok {
+			t.Errorf("schema is missing property %q", key)
+		}
+	}
+	if len(schema.Properties) != len(keys) {
+		t.Errorf("schema has %d properties, test knows %d; keep them in sync", len(schema.Properties), len(keys))
+	}
+}
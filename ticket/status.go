@@ -0,0 +1,80 @@
+package ticket
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Normalised ticket states reported in Metadata["state"] and accepted in
+// TicketQuery.Statuses. They come from the status category, so they mean
+// the same thing across workflows with different status names.
+const (
+	StateOpen       = "open"
+	StateInProgress = "in_progress"
+	StateClosed     = "closed"
+)
+
+// Jira's three status category keys.
+const (
+	categoryNew           = "new"
+	categoryIndeterminate = "indeterminate"
+	categoryDone          = "done"
+)
+
+// stateCategories maps each normalised state to the JQL name of its status
+// category.
+var stateCategories = map[string]string{
+	StateOpen:       "To Do",
+	StateInProgress: "In Progress",
+	StateClosed:     "Done",
+}
+
+// jiraStatusCategory is fields.status.statusCategory.
+type jiraStatusCategory struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// normalizedState derives the OpsOrch state from the status category. An
+// issue without a category counts as closed once it has a resolution.
+func normalizedState(categoryKey string, resolved bool) string {
+	switch categoryKey {
+	case categoryNew:
+		return StateOpen
+	case categoryIndeterminate:
+		return StateInProgress
+	case categoryDone:
+		return StateClosed
+	}
+	if resolved {
+		return StateClosed
+	}
+	return ""
+}
+
+// statusClause builds the JQL for TicketQuery.Statuses. Lower-case
+// normalised states become statusCategory filters; anything else is a
+// literal status name, so a workflow status called "Open" still matches by
+// name.
+func statusClause(statuses []string) string {
+	var names, categories []string
+	for _, s := range statuses {
+		if category, ok := stateCategories[s]; ok {
+			categories = append(categories, fmt.Sprintf("\"%s\"", category))
+			continue
+		}
+		names = append(names, fmt.Sprintf("\"%s\"", escapeJQL(s)))
+	}
+
+	var clauses []string
+	if len(names) > 0 {
+		clauses = append(clauses, fmt.Sprintf("status IN (%s)", strings.Join(names, ",")))
+	}
+	if len(categories) > 0 {
+		clauses = append(clauses, fmt.Sprintf("statusCategory IN (%s)", strings.Join(categories, ",")))
+	}
+	if len(clauses) == 1 {
+		return clauses[0]
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")"
+}
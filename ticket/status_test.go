@@ -0,0 +1,36 @@
+package ticket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestConvertJiraIssueState(t *testing.T) {
+	tests := []struct {
+		name       string
+		fields     string
+		state      string
+		resolution string
+	}{
+		{name: "new", fields: `{"status":{"name":"Triage","statusCategory":{"key":"new","name":"To Do"}}}`, state: StateOpen},
+		{name: "indeterminate", fields: `{"status":{"name":"Investigating","statusCategory":{"key":"indeterminate"}}}`, state: StateInProgress},
+		{name: "done", fields: `{"status":{"name":"Won't Do","statusCategory":{"key":"done"}},"resolution":{"name":"Won't Do"}}`, state: StateClosed, resolution: "Won't Do"},
+		{name: "resolution without category", fields: `{"status":{"name":"Fixed"},"resolution":{"name":"Fixed"}}`, state: StateClosed, resolution: "Fixed"},
+		{name: "unknown", fields: `{"status":{"name":"Open"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var issue jiraIssue
+			if err := json.Unmarshal([]byte(`{"key":"PROJ-1","fields":`+tt.fields+`}`), &issue); err != nil {
+				t.Fatal(err)
+			}
+			ticket := convertJiraIssue(issue, "jira", "https://example.atlassian.net")
+			if state, _ := ticket.Metadata["state"].(string); state != tt.state {
+				t.Errorf("state = %q, want %q", state, tt.state)
+			}
+			if resolution, _ := ticket.Metadata["resolution"].(string); resolution != tt.resolution {
+				t.Errorf("resolution = %q, want %q", resolution, tt.resolution)
+			}
+		})
+	}
+}
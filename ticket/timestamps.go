@@ -0,0 +1,41 @@
+package ticket
+
+import (
+	"strings"
+	"time"
+)
+
+// jiraTimeLayouts covers the date-time shapes Jira emits. Cloud and Data
+// Center both write offsets without a colon ("+0000"), which RFC 3339
+// parsing rejects; fractional seconds are accepted by every layout.
+var jiraTimeLayouts = []string{
+	"2006-01-02T15:04:05Z0700",
+	time.RFC3339,
+	"2006-01-02T15:04Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05Z0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+}
+
+// jiraDateLayout is used for date-only fields such as duedate.
+const jiraDateLayout = "2006-01-02"
+
+// parseJiraTime parses any Jira date or date-time value and normalises it to
+// UTC. Values without an offset are taken as UTC, and a bare date is
+// midnight UTC.
+func parseJiraTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range jiraTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	if t, err := time.Parse(jiraDateLayout, s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
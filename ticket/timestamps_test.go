@@ -0,0 +1,67 @@
+package ticket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseJiraTime(t *testing.T) {
+	want := time.Date(2024, 5, 1, 10, 22, 33, 123000000, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{in: "2024-05-01T10:22:33.123+0000", want: want, ok: true},
+		{in: "2024-05-01T12:22:33.123+0200", want: want, ok: true},
+		{in: "2024-05-01T10:22:33.123Z", want: want, ok: true},
+		{in: "2024-05-01T05:22:33.123-05:00", want: want, ok: true},
+		{in: "2024-05-01T10:22:33+0000", want: want.Truncate(time.Second), ok: true},
+		{in: "2024-05-01T10:22+0000", want: time.Date(2024, 5, 1, 10, 22, 0, 0, time.UTC), ok: true},
+		{in: "2024-05-01 10:22:33", want: want.Truncate(time.Second), ok: true},
+		{in: "2024-05-01", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ok: true},
+		{in: "", ok: false},
+		{in: "yesterday", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := parseJiraTime(tt.in)
+			if ok != tt.ok || !got.Equal(tt.want) || (ok && got.Location() != time.UTC) {
+				t.Errorf("parseJiraTime(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestConvertJiraIssueLifecycleDates(t *testing.T) {
+	var issue jiraIssue
+	raw := `{"id":"1","key":"PROJ-1","fields":{
+		"created":"2024-05-01T10:00:00.000+0000",
+		"updated":"2024-05-02T10:00:00.000+0000",
+		"resolutiondate":"2024-05-01T14:30:00.000+0000",
+		"duedate":"2024-05-10",
+		"statuscategorychangedate":"2024-05-01T14:30:00.000+0000",
+		"lastViewed":null}}`
+	if err := json.Unmarshal([]byte(raw), &issue); err != nil {
+		t.Fatal(err)
+	}
+	ticket := convertJiraIssue(issue, "jira", "https://example.atlassian.net")
+
+	if ticket.CreatedAt.IsZero() || ticket.UpdatedAt.IsZero() {
+		t.Errorf("CreatedAt = %v, UpdatedAt = %v, want both parsed", ticket.CreatedAt, ticket.UpdatedAt)
+	}
+	resolved, ok := ticket.Metadata["resolved_at"].(time.Time)
+	if !ok || resolved.Sub(ticket.CreatedAt) != 270*time.Minute {
+		t.Errorf("resolved_at = %v, want 4h30m after creation", ticket.Metadata["resolved_at"])
+	}
+	if due := ticket.Metadata["due_date"]; due != time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("due_date = %v", due)
+	}
+	if _, ok := ticket.Metadata["status_category_changed_at"].(time.Time); !ok {
+		t.Errorf("status_category_changed_at = %v", ticket.Metadata["status_category_changed_at"])
+	}
+	if _, ok := ticket.Metadata["last_viewed_at"]; ok {
+		t.Error("last_viewed_at set for a null value")
+	}
+}
@@ -0,0 +1,121 @@
+package ticket
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UpdateTicketInput.Fields keys that travel with the status transition
+// rather than the field update. They are ignored when no status is set.
+const (
+	// FieldResolution names the resolution, e.g. "Fixed".
+	FieldResolution = "resolution"
+	// FieldTransitionFields holds transition screen values by field ID,
+	// e.g. a required root-cause custom field.
+	FieldTransitionFields = "transitionFields"
+	// FieldTransitionComment is a comment added by the transition.
+	FieldTransitionComment = "transitionComment"
+)
+
+// transitionField reports whether an update field belongs to the
+// transition.
+func transitionField(key string) bool {
+	return key == FieldResolution || key == FieldTransitionFields || key == FieldTransitionComment
+}
+
+// transitionInput is what an update supplies to the transitions it runs.
+type transitionInput struct {
+	// fields holds screen values by field ID.
+	fields map[string]any
+	// comment is cleared once a transition has carried it.
+	comment string
+}
+
+func transitionInputFrom(fields map[string]any) *transitionInput {
+	in := &transitionInput{fields: map[string]any{}}
+	if m, ok := fields[FieldTransitionFields].(map[string]any); ok {
+		for k, v := range m {
+			in.fields[k] = v
+		}
+	}
+	if r, ok := fields[FieldResolution].(string); ok && strings.TrimSpace(r) != "" {
+		in.fields["resolution"] = map[string]string{"name": strings.TrimSpace(r)}
+	}
+	in.comment, _ = fields[FieldTransitionComment].(string)
+	return in
+}
+
+// payload builds the POST body for t. Only values for fields on t's screen
+// are sent, since Jira rejects any other. The comment goes with the first
+// transition that requires one, else the last.
+func (in *transitionInput) payload(ctx context.Context, p *JiraProvider, t jiraTransition, last bool) (map[string]any, error) {
+	fields := map[string]any{}
+	for id, v := range in.fields {
+		if _, ok := t.Fields[id]; ok {
+			fields[id] = v
+		}
+	}
+	comment := in.comment != "" && (last || t.Fields["comment"].Required)
+
+	missing := map[string]string{}
+	for id, f := range t.Fields {
+		if !f.Required || f.HasDefaultValue {
+			continue
+		}
+		if _, ok := fields[id]; ok || (id == "comment" && comment) {
+			continue
+		}
+		missing[id] = f.Name
+	}
+	if len(missing) > 0 {
+		return nil, &TransitionFieldsError{Transition: t.Name, Missing: missing}
+	}
+
+	payload := map[string]any{
+		"transition": map[string]string{
+			"id": t.ID,
+		},
+	}
+	if len(fields) > 0 {
+		payload["fields"] = fields
+	}
+	if comment {
+		payload["update"] = map[string]any{
+			"comment": []any{map[string]any{"add": map[string]any{"body": p.descriptionValue(ctx, in.comment)}}},
+		}
+		in.comment = ""
+	}
+	return payload, nil
+}
+
+// TransitionFieldsError lists the fields a transition screen requires that
+// the update did not supply. It unwraps to ErrValidation.
+type TransitionFieldsError struct {
+	Transition string
+	// Missing maps each field ID to its display name.
+	Missing map[string]string
+}
+
+func (e *TransitionFieldsError) Error() string {
+	ids := make([]string, 0, len(e.Missing))
+	for id := range e.Missing {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for i, id := range ids {
+		if name := e.Missing[id]; name != "" && name != id {
+			ids[i] = fmt.Sprintf("%s (%s)", id, name)
+		}
+	}
+	return fmt.Sprintf("transition %q requires fields: %s", e.Transition, strings.Join(ids, ", "))
+}
+
+// Unwrap exposes the sentinel for errors.Is.
+func (e *TransitionFieldsError) Unwrap() error { return ErrValidation }
+
+// As converts to an orcherr.OpsOrchError that keeps the field list.
+func (e *TransitionFieldsError) As(target any) bool {
+	return setOpsOrchError(target, ErrValidation.(*kindError).code, e.Error())
+}
@@ -0,0 +1,86 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/opsorch/opsorch-core/schema"
+)
+
+func TestUpdateSendsTransitionFields(t *testing.T) {
+	var mu sync.Mutex
+	var puts int
+	var sent map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPut:
+			puts++
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/3/issue/OPS-1/transitions":
+			w.Write([]byte(`{"transitions":[{"id":"31","name":"Resolve","to":{"id":"5","name":"Resolved"},"fields":{
+				"resolution":{"required":true,"hasDefaultValue":false,"name":"Resolution"},
+				"customfield_10050":{"required":true,"hasDefaultValue":false,"name":"Root cause"},
+				"assignee":{"required":false,"hasDefaultValue":false,"name":"Assignee"}}}]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/3/issue/OPS-1/transitions":
+			json.NewDecoder(r.Body).Decode(&sent)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/3/issue/OPS-1":
+			w.Write([]byte(`{"id":"10001","key":"OPS-1","fields":{"summary":"Outage","status":{"name":"Resolved"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &JiraProvider{cfg: Config{APIURL: server.URL, ProjectKey: "OPS", Source: "jira"}, client: &http.Client{}}
+	status := "Resolved"
+
+	_, err := p.Update(context.Background(), "OPS-1", schema.UpdateTicketInput{
+		Status: &status,
+		Fields: map[string]any{FieldResolution: "Fixed"},
+	})
+	var fieldsErr *TransitionFieldsError
+	if !errors.As(err, &fieldsErr) || !errors.Is(err, ErrValidation) {
+		t.Fatalf("Update() error = %v, want a TransitionFieldsError", err)
+	}
+	if len(fieldsErr.Missing) != 1 || fieldsErr.Missing["customfield_10050"] != "Root cause" {
+		t.Errorf("Missing = %v, want only the root cause", fieldsErr.Missing)
+	}
+	if sent != nil {
+		t.Errorf("transition sent despite missing fields: %v", sent)
+	}
+
+	_, err = p.Update(context.Background(), "OPS-1", schema.UpdateTicketInput{
+		Status: &status,
+		Fields: map[string]any{
+			FieldResolution:        "Fixed",
+			FieldTransitionFields:  map[string]any{"customfield_10050": "Disk filled up", "customfield_99999": "not on screen"},
+			FieldTransitionComment: "Cleared old logs",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if puts != 0 {
+		t.Errorf("sent %d field updates, want transition fields kept out of the edit", puts)
+	}
+	fields, _ := sent["fields"].(map[string]any)
+	if res, _ := fields["resolution"].(map[string]any); res["name"] != "Fixed" || fields["customfield_10050"] != "Disk filled up" {
+		t.Errorf("transition fields = %v", fields)
+	}
+	if _, ok := fields["customfield_99999"]; ok {
+		t.Errorf("sent a field that is not on the transition screen")
+	}
+	update, _ := sent["update"].(map[string]any)
+	comments, _ := update["comment"].([]any)
+	if len(comments) != 1 {
+		t.Errorf("update = %v, want one comment", update)
+	}
+}
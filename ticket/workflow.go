@@ -0,0 +1,427 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// workflowCacheTTL bounds how long a fetched workflow graph is reused.
+// Workflows change rarely, but an edit should be picked up without a
+// restart.
+const workflowCacheTTL = 10 * time.Minute
+
+// errNoWorkflow reports that the issue's workflow cannot be read, so only
+// transitions available right now can be used.
+var errNoWorkflow = errors.New("workflow is not readable")
+
+// jiraTransition is a transition available on an issue in its current
+// status.
+type jiraTransition struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	To   struct {
+		ID             string             `json:"id"`
+		Name           string             `json:"name"`
+		StatusCategory jiraStatusCategory `json:"statusCategory"`
+	} `json:"to"`
+	// Fields is the transition screen, populated by expand=transitions.fields.
+	Fields map[string]jiraTransitionField `json:"fields"`
+}
+
+type jiraTransitionField struct {
+	Name            string `json:"name"`
+	Required        bool   `json:"required"`
+	HasDefaultValue bool   `json:"hasDefaultValue"`
+}
+
+// transitions returns the transitions available on an issue right now,
+// with their screen fields.
+func (p *JiraProvider) transitions(ctx context.Context, id string) ([]jiraTransition, error) {
+	resp, err := p.do(ctx, apiRequest{method: http.MethodGet, path: p.apiPath("/issue/" + id + "/transitions?expand=transitions.fields")})
+	if err != nil {
+		return nil, fmt.Errorf("get transitions: %w", err)
+	}
+	var out struct {
+		Transitions []jiraTransition `json:"transitions"`
+	}
+	if err := decodeResponse(resp, http.StatusOK, &out); err != nil {
+		return nil, fmt.Errorf("get transitions: %w", err)
+	}
+	return out.Transitions, nil
+}
+
+// matchTransition finds the transition leading to target, falling back to
+// one named after it so mappings may name transitions too.
+func matchTransition(available []jiraTransition, target string) (jiraTransition, bool) {
+	for _, t := range available {
+		if strings.EqualFold(t.To.Name, target) {
+			return t, true
+		}
+	}
+	for _, t := range available {
+		if strings.EqualFold(t.Name, target) {
+			return t, true
+		}
+	}
+	return jiraTransition{}, false
+}
+
+// workflowEdge is one transition in a workflow graph.
+type workflowEdge struct {
+	id   string
+	name string
+	// from holds source status IDs; empty for a global transition, which
+	// is available from every status.
+	from []string
+	to   string
+}
+
+func (e workflowEdge) leaves(status string) bool {
+	if len(e.from) == 0 {
+		return true
+	}
+	for _, s := range e.from {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// match finds the live transition for this edge in the issue's current
+// status.
+func (e workflowEdge) match(available []jiraTransition) (jiraTransition, bool) {
+	for _, t := range available {
+		if t.ID == e.id {
+			return t, true
+		}
+	}
+	for _, t := range available {
+		if t.To.ID == e.to {
+			return t, true
+		}
+	}
+	return jiraTransition{}, false
+}
+
+// workflowGraph is a workflow's statuses and the transitions between them.
+type workflowGraph struct {
+	// statuses maps status ID to name.
+	statuses map[string]string
+	edges    []workflowEdge
+}
+
+// path returns the fewest transitions from the status with ID from to a
+// status named target, or reached by a transition named target. It is
+// empty when the issue is already there and nil when there is no path.
+func (g *workflowGraph) path(from, target string) []workflowEdge {
+	goal := map[string]bool{}
+	for id, name := range g.statuses {
+		if strings.EqualFold(name, target) {
+			goal[id] = true
+		}
+	}
+	for _, e := range g.edges {
+		if strings.EqualFold(e.name, target) {
+			goal[e.to] = true
+		}
+	}
+	if goal[from] {
+		return []workflowEdge{}
+	}
+
+	type hop struct {
+		edge workflowEdge
+		from string
+	}
+	prev := map[string]hop{}
+	seen := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, e := range g.edges {
+			if seen[e.to] || !e.leaves(cur) {
+				continue
+			}
+			seen[e.to] = true
+			prev[e.to] = hop{edge: e, from: cur}
+			if !goal[e.to] {
+				queue = append(queue, e.to)
+				continue
+			}
+			var path []workflowEdge
+			for s := e.to; s != from; s = prev[s].from {
+				path = append([]workflowEdge{prev[s].edge}, path...)
+			}
+			return path
+		}
+	}
+	return nil
+}
+
+// workflowCache holds workflow graphs by project and issue type.
+type workflowCache struct {
+	mu      sync.Mutex
+	entries map[string]workflowEntry
+}
+
+type workflowEntry struct {
+	graph   *workflowGraph
+	fetched time.Time
+}
+
+// workflow returns the graph for an issue type in a project, fetching it
+// when it is not cached or has expired.
+func (p *JiraProvider) workflow(ctx context.Context, projectID, issueTypeID string) (*workflowGraph, error) {
+	key := projectID + "/" + issueTypeID
+	p.workflows.mu.Lock()
+	entry, ok := p.workflows.entries[key]
+	p.workflows.mu.Unlock()
+	if ok && time.Since(entry.fetched) < workflowCacheTTL {
+		return entry.graph, nil
+	}
+
+	graph, err := p.fetchWorkflow(ctx, projectID, issueTypeID)
+	if err != nil {
+		return nil, err
+	}
+	p.workflows.mu.Lock()
+	if p.workflows.entries == nil {
+		p.workflows.entries = map[string]workflowEntry{}
+	}
+	p.workflows.entries[key] = workflowEntry{graph: graph, fetched: time.Now()}
+	p.workflows.mu.Unlock()
+	return graph, nil
+}
+
+// fetchWorkflow reads the workflow from the project's workflow scheme.
+// Only Jira Cloud exposes workflow transitions over REST, and reading them
+// needs the Administer Jira permission.
+func (p *JiraProvider) fetchWorkflow(ctx context.Context, projectID, issueTypeID string) (*workflowGraph, error) {
+	if p.cfg.dataCenter() {
+		return nil, fmt.Errorf("%w: Data Center has no workflow API", errNoWorkflow)
+	}
+
+	resp, err := p.do(ctx, apiRequest{method: http.MethodGet, path: p.apiPath("/workflowscheme/project?" + url.Values{"projectId": {projectID}}.Encode())})
+	if err != nil {
+		return nil, fmt.Errorf("get workflow scheme: %w", err)
+	}
+	var schemes struct {
+		Values []struct {
+			WorkflowScheme struct {
+				DefaultWorkflow   string            `json:"defaultWorkflow"`
+				IssueTypeMappings map[string]string `json:"issueTypeMappings"`
+			} `json:"workflowScheme"`
+		} `json:"values"`
+	}
+	if err := decodeResponse(resp, http.StatusOK, &schemes); err != nil {
+		return nil, fmt.Errorf("get workflow scheme: %w", err)
+	}
+	if len(schemes.Values) == 0 {
+		return nil, fmt.Errorf("%w: project %s has no workflow scheme", errNoWorkflow, projectID)
+	}
+	scheme := schemes.Values[0].WorkflowScheme
+	name, ok := scheme.IssueTypeMappings[issueTypeID]
+	if !ok {
+		name = scheme.DefaultWorkflow
+	}
+
+	q := url.Values{"workflowName": {name}, "expand": {"transitions,statuses"}}
+	resp, err = p.do(ctx, apiRequest{method: http.MethodGet, path: p.apiPath("/workflow/search?" + q.Encode())})
+	if err != nil {
+		return nil, fmt.Errorf("get workflow %q: %w", name, err)
+	}
+	var workflows struct {
+		Values []struct {
+			Transitions []struct {
+				ID   string   `json:"id"`
+				Name string   `json:"name"`
+				From []string `json:"from"`
+				To   string   `json:"to"`
+				Type string   `json:"type"`
+			} `json:"transitions"`
+			Statuses []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"statuses"`
+		} `json:"values"`
+	}
+	if err := decodeResponse(resp, http.StatusOK, &workflows); err != nil {
+		return nil, fmt.Errorf("get workflow %q: %w", name, err)
+	}
+	if len(workflows.Values) == 0 {
+		return nil, fmt.Errorf("%w: workflow %q not found", errNoWorkflow, name)
+	}
+
+	wf := workflows.Values[0]
+	graph := &workflowGraph{statuses: map[string]string{}}
+	for _, s := range wf.Statuses {
+		graph.statuses[s.ID] = s.Name
+	}
+	for _, t := range wf.Transitions {
+		// The initial transition creates the issue; it cannot be taken.
+		if t.Type == "initial" {
+			continue
+		}
+		graph.edges = append(graph.edges, workflowEdge{id: t.ID, name: t.Name, from: t.From, to: t.To})
+	}
+	return graph, nil
+}
+
+// maxLiveSteps bounds how many transitions walkTransitions takes toward a
+// status it cannot see a path to.
+const maxLiveSteps = 5
+
+// categoryRank orders status categories along a workflow's usual flow.
+var categoryRank = map[string]int{categoryNew: 0, categoryIndeterminate: 1, categoryDone: 2}
+
+// walkTransitions moves an issue toward target without the workflow graph,
+// using only the transitions Jira offers from each status. It takes the
+// transition to target as soon as one is offered; until then it steps to
+// an unvisited status in the target's status category, else one whose
+// category lies closer to it, for at most maxLiveSteps steps.
+func (p *JiraProvider) walkTransitions(ctx context.Context, id, target string, available []jiraTransition, in *transitionInput) error {
+	goal, err := p.statusCategory(ctx, target)
+	if err != nil {
+		return err
+	}
+	resp, err := p.do(ctx, apiRequest{method: http.MethodGet, path: p.apiPath("/issue/" + id + "?fields=status")})
+	if err != nil {
+		return err
+	}
+	var issue struct {
+		Fields struct {
+			Status struct {
+				ID             string             `json:"id"`
+				Name           string             `json:"name"`
+				StatusCategory jiraStatusCategory `json:"statusCategory"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	if err := decodeResponse(resp, http.StatusOK, &issue); err != nil {
+		return err
+	}
+	current := issue.Fields.Status.Name
+	category := issue.Fields.Status.StatusCategory.Key
+	visited := map[string]bool{issue.Fields.Status.ID: true}
+
+	for step := 0; ; step++ {
+		if step > 0 {
+			if available, err = p.transitions(ctx, id); err != nil {
+				return fmt.Errorf("transition to %s stopped in status %q after %d steps: %w", target, current, step, err)
+			}
+		}
+		if t, ok := matchTransition(available, target); ok {
+			if err := p.runTransition(ctx, id, t, in, true); err != nil {
+				return fmt.Errorf("transition to %s stopped in status %q after %d steps: %w", target, current, step, err)
+			}
+			return nil
+		}
+		next, ok := towardCategory(available, goal, category, visited)
+		if !ok || step == maxLiveSteps {
+			if step == 0 {
+				return fmt.Errorf("%w: no transition found to status: %s", ErrValidation, target)
+			}
+			return fmt.Errorf("%w: transition to %s stopped in status %q after %d steps: no transition leads closer", ErrValidation, target, current, step)
+		}
+		if err := p.runTransition(ctx, id, next, in, false); err != nil {
+			return fmt.Errorf("transition to %s stopped in status %q after %d steps: %w", target, current, step, err)
+		}
+		visited[next.To.ID] = true
+		current, category = next.To.Name, next.To.StatusCategory.Key
+	}
+}
+
+// towardCategory picks the first offered transition to an unvisited status
+// in category goal, else one moving from category cur closer to goal.
+func towardCategory(available []jiraTransition, goal, cur string, visited map[string]bool) (jiraTransition, bool) {
+	distance := func(category string) int {
+		d := categoryRank[category] - categoryRank[goal]
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	var closer *jiraTransition
+	for i, t := range available {
+		if visited[t.To.ID] {
+			continue
+		}
+		if t.To.StatusCategory.Key == goal {
+			return t, true
+		}
+		if closer == nil && distance(t.To.StatusCategory.Key) < distance(cur) {
+			closer = &available[i]
+		}
+	}
+	if closer == nil {
+		return jiraTransition{}, false
+	}
+	return *closer, true
+}
+
+// statusCategory returns the category key of the status named name.
+func (p *JiraProvider) statusCategory(ctx context.Context, name string) (string, error) {
+	resp, err := p.do(ctx, apiRequest{method: http.MethodGet, path: p.apiPath("/status/" + url.PathEscape(name))})
+	if err != nil {
+		return "", fmt.Errorf("get status %q: %w", name, err)
+	}
+	var status struct {
+		StatusCategory jiraStatusCategory `json:"statusCategory"`
+	}
+	if err := decodeResponse(resp, http.StatusOK, &status); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return "", fmt.Errorf("%w: no transition found to status: %s", ErrValidation, name)
+		}
+		return "", fmt.Errorf("get status %q: %w", name, err)
+	}
+	return status.StatusCategory.Key, nil
+}
+
+// transitionPath plans the shortest route from the issue's current status
+// to target, returning the steps and the status they start from.
+func (p *JiraProvider) transitionPath(ctx context.Context, id, target string) ([]workflowEdge, string, error) {
+	resp, err := p.do(ctx, apiRequest{method: http.MethodGet, path: p.apiPath("/issue/" + id + "?fields=status,project,issuetype")})
+	if err != nil {
+		return nil, "", err
+	}
+	var issue struct {
+		Fields struct {
+			Status struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"status"`
+			Project struct {
+				ID string `json:"id"`
+			} `json:"project"`
+			IssueType struct {
+				ID string `json:"id"`
+			} `json:"issuetype"`
+		} `json:"fields"`
+	}
+	if err := decodeResponse(resp, http.StatusOK, &issue); err != nil {
+		return nil, "", err
+	}
+	current := issue.Fields.Status
+
+	graph, err := p.workflow(ctx, issue.Fields.Project.ID, issue.Fields.IssueType.ID)
+	if !errors.Is(err, errNoWorkflow) && (errors.Is(err, ErrForbidden) || errors.Is(err, ErrNotFound)) {
+		return nil, "", fmt.Errorf("%w: %v", errNoWorkflow, err)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	path := graph.path(current.ID, target)
+	if path == nil {
+		return nil, "", fmt.Errorf("%w: no transition path from status %q to %s", ErrValidation, current.Name, target)
+	}
+	return path, current.Name, nil
+}
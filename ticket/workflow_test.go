@@ -0,0 +1,220 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// workflowServer simulates an issue moving through Open -> In Progress ->
+// Resolved -> Closed, with a global "Reopen" transition back to Open.
+// screens maps a transition ID to the fields its screen requires. A
+// non-zero workflowStatus is returned by the workflow endpoints instead,
+// as for a user without the Administer Jira permission. Both REST versions
+// are served, so the issue works for Data Center too.
+func workflowServer(t *testing.T, screens map[string][]string, workflowStatus int) (*httptest.Server, func() []string) {
+	t.Helper()
+	statuses := map[string]string{"1": "Open", "3": "In Progress", "5": "Resolved", "6": "Closed"}
+	categories := map[string]string{"1": categoryNew, "3": categoryIndeterminate, "5": categoryDone, "6": categoryDone}
+	transitions := []struct {
+		id, name string
+		from     []string
+		to       string
+	}{
+		{"11", "Start", []string{"1"}, "3"},
+		{"21", "Resolve", []string{"3"}, "5"},
+		{"31", "Close", []string{"5"}, "6"},
+		{"41", "Reopen", nil, "1"},
+	}
+
+	var mu sync.Mutex
+	current := "1"
+	var taken []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path := "/rest/api/3" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/rest/api/3"), "/rest/api/2")
+		switch {
+		case workflowStatus != 0 && (strings.HasPrefix(path, "/rest/api/3/workflow") || strings.HasPrefix(path, "/rest/api/3/workflowscheme")):
+			w.WriteHeader(workflowStatus)
+		case strings.HasPrefix(path, "/rest/api/3/status/"):
+			name := strings.TrimPrefix(path, "/rest/api/3/status/")
+			for id, n := range statuses {
+				if strings.EqualFold(n, name) {
+					json.NewEncoder(w).Encode(map[string]any{"id": id, "name": n, "statusCategory": map[string]any{"key": categories[id]}})
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		case path == "/rest/api/3/issue/OPS-1/transitions" && r.Method == http.MethodGet:
+			if r.URL.Query().Get("expand") != "transitions.fields" {
+				t.Errorf("transitions requested without expand=transitions.fields")
+			}
+			var out []map[string]any
+			for _, tr := range transitions {
+				if len(tr.from) > 0 && tr.from[0] != current {
+					continue
+				}
+				fields := map[string]any{}
+				for _, f := range screens[tr.id] {
+					fields[f] = map[string]any{"required": true, "hasDefaultValue": false, "name": f}
+				}
+				to := map[string]any{"id": tr.to, "name": statuses[tr.to], "statusCategory": map[string]any{"key": categories[tr.to]}}
+				out = append(out, map[string]any{"id": tr.id, "name": tr.name, "to": to, "fields": fields})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"transitions": out})
+		case path == "/rest/api/3/issue/OPS-1/transitions" && r.Method == http.MethodPost:
+			var payload struct {
+				Transition struct {
+					ID string `json:"id"`
+				} `json:"transition"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			for _, tr := range transitions {
+				if tr.id == payload.Transition.ID {
+					current = tr.to
+				}
+			}
+			taken = append(taken, payload.Transition.ID)
+			w.WriteHeader(http.StatusNoContent)
+		case path == "/rest/api/3/issue/OPS-1":
+			fmt.Fprintf(w, `{"id":"10001","key":"OPS-1","fields":{"status":{"id":%q,"name":%q,"statusCategory":{"key":%q}},"project":{"id":"10000"},"issuetype":{"id":"10002"}}}`, current, statuses[current], categories[current])
+		case path == "/rest/api/3/workflowscheme/project":
+			w.Write([]byte(`{"values":[{"workflowScheme":{"defaultWorkflow":"jira","issueTypeMappings":{"10002":"Incident Workflow"}}}]}`))
+		case path == "/rest/api/3/workflow/search":
+			if r.URL.Query().Get("workflowName") != "Incident Workflow" {
+				w.Write([]byte(`{"values":[]}`))
+				return
+			}
+			var ts []map[string]any
+			ts = append(ts, map[string]any{"id": "1", "name": "Create", "from": []string{}, "to": "1", "type": "initial"})
+			for _, tr := range transitions {
+				typ := "directed"
+				if tr.from == nil {
+					typ = "global"
+				}
+				ts = append(ts, map[string]any{"id": tr.id, "name": tr.name, "from": tr.from, "to": tr.to, "type": typ})
+			}
+			var ss []map[string]any
+			for id, name := range statuses {
+				ss = append(ss, map[string]any{"id": id, "name": name})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"values": []any{map[string]any{"transitions": ts, "statuses": ss}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), taken...)
+	}
+}
+
+func TestTransitionIssueMultiHop(t *testing.T) {
+	server, taken := workflowServer(t, nil, 0)
+	defer server.Close()
+	p := &JiraProvider{cfg: Config{APIURL: server.URL, ProjectKey: "OPS"}, client: &http.Client{}}
+
+	if err := p.transitionIssue(context.Background(), "OPS-1", "Closed", transitionInputFrom(nil)); err != nil {
+		t.Fatalf("transitionIssue() error = %v", err)
+	}
+	if got := strings.Join(taken(), ","); got != "11,21,31" {
+		t.Errorf("transitions taken = %s, want 11,21,31", got)
+	}
+
+	// Already there: nothing to do.
+	if err := p.transitionIssue(context.Background(), "OPS-1", "closed", transitionInputFrom(nil)); err != nil {
+		t.Fatalf("transitionIssue() to the current status error = %v", err)
+	}
+	if n := len(taken()); n != 3 {
+		t.Errorf("took %d transitions, want none for the current status", n-3)
+	}
+}
+
+func TestTransitionIssueStopsOnRequiredFields(t *testing.T) {
+	server, taken := workflowServer(t, map[string][]string{"21": {"resolution", "customfield_10050"}}, 0)
+	defer server.Close()
+	p := &JiraProvider{cfg: Config{APIURL: server.URL, ProjectKey: "OPS"}, client: &http.Client{}}
+
+	err := p.transitionIssue(context.Background(), "OPS-1", "Closed", transitionInputFrom(nil))
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("transitionIssue() error = %v, want ErrValidation", err)
+	}
+	for _, want := range []string{`stopped in status "In Progress" after 1 of 3 steps`, "customfield_10050, resolution"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if got := strings.Join(taken(), ","); got != "11" {
+		t.Errorf("transitions taken = %s, want only 11", got)
+	}
+}
+
+func TestTransitionIssueWithoutWorkflow(t *testing.T) {
+	tests := []struct {
+		name           string
+		deployment     string
+		workflowStatus int
+	}{
+		{name: "forbidden", deployment: DeploymentCloud, workflowStatus: http.StatusForbidden},
+		{name: "data center", deployment: DeploymentDataCenter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, taken := workflowServer(t, nil, tt.workflowStatus)
+			defer server.Close()
+			p := &JiraProvider{cfg: Config{APIURL: server.URL, ProjectKey: "OPS", Deployment: tt.deployment}, client: &http.Client{}}
+
+			if err := p.transitionIssue(context.Background(), "OPS-1", "Closed", transitionInputFrom(nil)); err != nil {
+				t.Fatalf("transitionIssue() error = %v", err)
+			}
+			if got := strings.Join(taken(), ","); got != "11,21,31" {
+				t.Errorf("transitions taken = %s, want 11,21,31 through the live transitions", got)
+			}
+
+			err := p.transitionIssue(context.Background(), "OPS-1", "Archived", transitionInputFrom(nil))
+			if !errors.Is(err, ErrValidation) {
+				t.Errorf("transitionIssue() to an unknown status error = %v, want ErrValidation", err)
+			}
+		})
+	}
+}
+
+func TestWorkflowGraphPath(t *testing.T) {
+	g := &workflowGraph{
+		statuses: map[string]string{"1": "Open", "3": "In Progress", "5": "Resolved", "6": "Closed"},
+		edges: []workflowEdge{
+			{id: "11", name: "Start", from: []string{"1"}, to: "3"},
+			{id: "21", name: "Resolve", from: []string{"3"}, to: "5"},
+			{id: "31", name: "Close", from: []string{"5"}, to: "6"},
+			{id: "41", name: "Reopen", to: "1"},
+		},
+	}
+	steps := func(path []workflowEdge) string {
+		var ids []string
+		for _, e := range path {
+			ids = append(ids, e.id)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	if got := steps(g.path("1", "Closed")); got != "11,21,31" {
+		t.Errorf("path(Open, Closed) = %s, want 11,21,31", got)
+	}
+	if got := steps(g.path("6", "In Progress")); got != "41,11" {
+		t.Errorf("path(Closed, In Progress) = %s, want 41,11 via the global transition", got)
+	}
+	if got := steps(g.path("1", "Resolve")); got != "11,21" {
+		t.Errorf("path(Open, Resolve) = %s, want 11,21 by transition name", got)
+	}
+	if g.path("1", "Archived") != nil {
+		t.Errorf("path to an unknown status is not nil")
+	}
+}
@@ -1,6 +1,6 @@
 package adapter
 
 const (
-	AdapterVersion = "0.1.0"
-	RequiresCore   = ">=0.1.0"
+	AdapterVersion = "0.2.0"
+	RequiresCore   = ">=0.4.0"
 )